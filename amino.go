@@ -0,0 +1,85 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Coin is an amount of a denom as encoded in amino JSON
+type Coin struct {
+	Amount string `json:"amount"`
+	Denom  string `json:"denom"`
+}
+
+// StdFee is the fee of a legacy amino transaction
+type StdFee struct {
+	Amount  []Coin `json:"amount"`
+	Gas     uint64 `json:"gas,string"`
+	Payer   string `json:"payer,omitempty"`
+	Granter string `json:"granter,omitempty"`
+}
+
+// StdSignDoc is the document signed in SIGN_MODE_LEGACY_AMINO_JSON (P2=0).
+// Msgs hold the amino JSON of each message, e.g. {"type":"cosmos-sdk/MsgSend","value":{...}}.
+type StdSignDoc struct {
+	AccountNumber uint64            `json:"account_number,string"`
+	ChainID       string            `json:"chain_id"`
+	Fee           StdFee            `json:"fee"`
+	Memo          string            `json:"memo"`
+	Msgs          []json.RawMessage `json:"msgs"`
+	Sequence      uint64            `json:"sequence,string"`
+	TimeoutHeight uint64            `json:"timeout_height,omitempty,string"`
+}
+
+// Marshal returns the canonical sign bytes: keys sorted at every level, no whitespace
+// and HTML characters escaped, exactly like the Cosmos SDK legacy amino sign mode.
+func (doc StdSignDoc) Marshal() ([]byte, error) {
+	// the SDK renders empty fees and message lists as [] rather than null
+	if doc.Fee.Amount == nil {
+		doc.Fee.Amount = []Coin{}
+	}
+	if doc.Msgs == nil {
+		doc.Msgs = []json.RawMessage{}
+	}
+
+	bz, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return SortJSON(bz)
+}
+
+// SortJSON re-encodes a JSON document with sorted object keys and no whitespace.
+// Numbers are kept verbatim.
+func SortJSON(bz []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(bz))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after the top-level value")
+	}
+
+	// encoding/json sorts map keys
+	return json.Marshal(value)
+}
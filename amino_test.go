@@ -0,0 +1,78 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StdSignDocMarshal(t *testing.T) {
+	doc := StdSignDoc{
+		AccountNumber: 1,
+		ChainID:       "cosmoshub-4",
+		Fee: StdFee{
+			Amount: []Coin{{Amount: "5000", Denom: "uatom"}},
+			Gas:    200000,
+		},
+		Memo: "<memo> & more",
+		Msgs: []json.RawMessage{json.RawMessage(`{
+			"type": "cosmos-sdk/MsgSend",
+			"value": {
+				"to_address": "cosmos1to",
+				"from_address": "cosmos1from",
+				"amount": [{"denom": "uatom", "amount": "10"}]
+			}
+		}`)},
+		Sequence: 3,
+	}
+
+	bz, err := doc.Marshal()
+	require.NoError(t, err)
+
+	expected := `{"account_number":"1","chain_id":"cosmoshub-4",` +
+		`"fee":{"amount":[{"amount":"5000","denom":"uatom"}],"gas":"200000"},` +
+		`"memo":"\u003cmemo\u003e \u0026 more",` +
+		`"msgs":[{"type":"cosmos-sdk/MsgSend","value":{"amount":[{"amount":"10","denom":"uatom"}],"from_address":"cosmos1from","to_address":"cosmos1to"}}],` +
+		`"sequence":"3"}`
+	assert.Equal(t, expected, string(bz))
+}
+
+func Test_StdSignDocMarshalEmpty(t *testing.T) {
+	bz, err := StdSignDoc{TimeoutHeight: 100}.Marshal()
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		`{"account_number":"0","chain_id":"","fee":{"amount":[],"gas":"0"},"memo":"","msgs":[],"sequence":"0","timeout_height":"100"}`,
+		string(bz))
+}
+
+func Test_SortJSON(t *testing.T) {
+	bz, err := SortJSON(getDummyTx())
+	require.NoError(t, err)
+	assert.Equal(t, getDummyTx(), bz)
+
+	bz, err = SortJSON([]byte(`{"b": 12345678901234567890, "a": [true, null]}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[true,null],"b":12345678901234567890}`, string(bz))
+
+	_, err = SortJSON([]byte(`{"a":1} {}`))
+	assert.Error(t, err)
+}
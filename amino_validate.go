@@ -0,0 +1,390 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DeviceModel identifies the Ledger device model running the app
+type DeviceModel int
+
+const (
+	DeviceModelUnknown DeviceModel = iota
+	DeviceModelNanoS
+	DeviceModelNanoX
+	DeviceModelNanoSPlus
+	DeviceModelStax
+	DeviceModelFlex
+)
+
+func (m DeviceModel) String() string {
+	switch m {
+	case DeviceModelNanoS:
+		return "Nano S"
+	case DeviceModelNanoX:
+		return "Nano X"
+	case DeviceModelNanoSPlus:
+		return "Nano S Plus"
+	case DeviceModelStax:
+		return "Stax"
+	case DeviceModelFlex:
+		return "Flex"
+	default:
+		return "unknown device"
+	}
+}
+
+// deviceModelFromTargetID maps the target id reported by the app to a model.
+// The low byte changes with the firmware generation and is ignored.
+func deviceModelFromTargetID(targetID uint32) DeviceModel {
+	switch targetID >> 8 {
+	case 0x311000:
+		return DeviceModelNanoS
+	case 0x330000:
+		return DeviceModelNanoX
+	case 0x331000:
+		return DeviceModelNanoSPlus
+	case 0x332000:
+		return DeviceModelStax
+	case 0x333000:
+		return DeviceModelFlex
+	default:
+		return DeviceModelUnknown
+	}
+}
+
+// MaxJSONTokens returns how many JSMN tokens the Cosmos app can parse on this model.
+// Unknown models get the largest limit and are left to the device to check.
+func (m DeviceModel) MaxJSONTokens() int {
+	if m == DeviceModelNanoS {
+		return 256
+	}
+	return 768
+}
+
+// requiredAminoFields are the top-level fields the Cosmos app expects in an amino sign doc
+var requiredAminoFields = []string{"account_number", "chain_id", "fee", "memo", "msgs", "sequence"}
+
+// AminoValidationError describes why an amino JSON sign doc would be refused by the device
+type AminoValidationError struct {
+	// Offset is the position of the offending byte in the payload
+	Offset int
+	Reason string
+}
+
+func (e *AminoValidationError) Error() string {
+	return fmt.Sprintf("invalid amino JSON at byte %d: %s", e.Offset, e.Reason)
+}
+
+// ValidateAminoJSON checks locally that payload is a sign doc the Cosmos app can parse on the
+// given model: ASCII only, no whitespace, sorted keys, the required top-level fields and a
+// JSMN token count within the device limit. It returns an *AminoValidationError.
+func ValidateAminoJSON(payload []byte, model DeviceModel) error {
+	for i, b := range payload {
+		if b >= 0x80 {
+			return &AminoValidationError{i, "non-ASCII character"}
+		}
+	}
+
+	s := &aminoScanner{data: payload, maxTokens: model.MaxJSONTokens(), model: model}
+	if len(payload) == 0 || payload[0] != '{' {
+		return s.fail(0, "the sign doc must be a JSON object")
+	}
+
+	keys, err := s.object()
+	if err != nil {
+		return err
+	}
+	if s.pos != len(payload) {
+		return s.fail(s.pos, "unexpected data after the sign doc")
+	}
+
+	for _, field := range requiredAminoFields {
+		if _, ok := keys[field]; !ok {
+			return s.fail(0, fmt.Sprintf("missing required field %q", field))
+		}
+	}
+	return nil
+}
+
+// aminoScanner walks a JSON document the way the device parser does, counting tokens
+type aminoScanner struct {
+	data      []byte
+	pos       int
+	tokens    int
+	maxTokens int
+	model     DeviceModel
+}
+
+func (s *aminoScanner) fail(offset int, reason string) error {
+	return &AminoValidationError{Offset: offset, Reason: reason}
+}
+
+func (s *aminoScanner) token() error {
+	s.tokens++
+	if s.tokens > s.maxTokens {
+		return s.fail(s.pos, fmt.Sprintf("more than %d JSON tokens, the limit for %s", s.maxTokens, s.model))
+	}
+	return nil
+}
+
+func (s *aminoScanner) expect(c byte) error {
+	if s.pos >= len(s.data) {
+		return s.fail(s.pos, "unexpected end of input")
+	}
+	if s.data[s.pos] != c {
+		return s.unexpected()
+	}
+	s.pos++
+	return nil
+}
+
+func (s *aminoScanner) unexpected() error {
+	if s.pos >= len(s.data) {
+		return s.fail(s.pos, "unexpected end of input")
+	}
+	switch c := s.data[s.pos]; c {
+	case ' ', '\t', '\n', '\r':
+		return s.fail(s.pos, "whitespace is not allowed")
+	default:
+		return s.fail(s.pos, fmt.Sprintf("unexpected character %q", c))
+	}
+}
+
+func (s *aminoScanner) value() error {
+	if s.pos >= len(s.data) {
+		return s.fail(s.pos, "unexpected end of input")
+	}
+	switch s.data[s.pos] {
+	case '{':
+		_, err := s.object()
+		return err
+	case '[':
+		return s.array()
+	case '"':
+		_, err := s.string()
+		return err
+	default:
+		return s.primitive()
+	}
+}
+
+// object scans an object and returns its keys
+func (s *aminoScanner) object() (map[string]struct{}, error) {
+	if err := s.token(); err != nil {
+		return nil, err
+	}
+	if err := s.expect('{'); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]struct{})
+	if s.pos < len(s.data) && s.data[s.pos] == '}' {
+		s.pos++
+		return keys, nil
+	}
+
+	var previous []byte
+	for {
+		keyOffset := s.pos
+		if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+			return nil, s.unexpected()
+		}
+		key, err := s.string()
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil && bytes.Compare(previous, key) >= 0 {
+			return nil, s.fail(keyOffset, fmt.Sprintf("key %q is not sorted after %q", key, previous))
+		}
+		previous = key
+		keys[string(key)] = struct{}{}
+
+		if err := s.expect(':'); err != nil {
+			return nil, err
+		}
+		if err := s.value(); err != nil {
+			return nil, err
+		}
+
+		if s.pos < len(s.data) && s.data[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		return keys, s.expect('}')
+	}
+}
+
+func (s *aminoScanner) array() error {
+	if err := s.token(); err != nil {
+		return err
+	}
+	if err := s.expect('['); err != nil {
+		return err
+	}
+
+	if s.pos < len(s.data) && s.data[s.pos] == ']' {
+		s.pos++
+		return nil
+	}
+
+	for {
+		if err := s.value(); err != nil {
+			return err
+		}
+		if s.pos < len(s.data) && s.data[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		return s.expect(']')
+	}
+}
+
+// string scans a string and returns its raw content without the quotes
+func (s *aminoScanner) string() ([]byte, error) {
+	if err := s.token(); err != nil {
+		return nil, err
+	}
+	if err := s.expect('"'); err != nil {
+		return nil, err
+	}
+
+	start := s.pos
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		switch {
+		case c == '"':
+			content := s.data[start:s.pos]
+			s.pos++
+			return content, nil
+		case c < 0x20:
+			return nil, s.fail(s.pos, "control characters must be escaped")
+		case c == '\\':
+			if err := s.escape(); err != nil {
+				return nil, err
+			}
+		default:
+			s.pos++
+		}
+	}
+	return nil, s.fail(s.pos, "unterminated string")
+}
+
+func (s *aminoScanner) escape() error {
+	offset := s.pos
+	s.pos++
+	if s.pos >= len(s.data) {
+		return s.fail(offset, "unterminated escape sequence")
+	}
+
+	switch s.data[s.pos] {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		s.pos++
+		return nil
+	case 'u':
+		s.pos++
+		for i := 0; i < 4; i++ {
+			if s.pos >= len(s.data) || !isHexDigit(s.data[s.pos]) {
+				return s.fail(offset, "invalid unicode escape sequence")
+			}
+			s.pos++
+		}
+		return nil
+	default:
+		return s.fail(offset, "invalid escape sequence")
+	}
+}
+
+// primitive scans a number, true, false or null
+func (s *aminoScanner) primitive() error {
+	if err := s.token(); err != nil {
+		return err
+	}
+
+	start := s.pos
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if !isPrimitiveChar(c) {
+			if s.pos == start {
+				return s.unexpected()
+			}
+			return s.fail(s.pos, fmt.Sprintf("unexpected character %q", c))
+		}
+		s.pos++
+	}
+
+	literal := s.data[start:s.pos]
+	switch string(literal) {
+	case "true", "false", "null":
+		return nil
+	}
+	if !isJSONNumber(literal) {
+		return s.fail(start, fmt.Sprintf("invalid literal %q", literal))
+	}
+	return nil
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isPrimitiveChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || c == '-' || c == '+' || c == '.' || c == 'E'
+}
+
+// isJSONNumber checks the JSON number grammar: -?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?
+func isJSONNumber(b []byte) bool {
+	i := 0
+	digits := func() int {
+		n := 0
+		for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+	if i < len(b) && b[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return false
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(b)
+}
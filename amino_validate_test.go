@@ -0,0 +1,125 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireValidationError(t *testing.T, err error, offset int, reason string) {
+	t.Helper()
+	var validationErr *AminoValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, offset, validationErr.Offset, validationErr.Reason)
+	assert.Contains(t, validationErr.Reason, reason)
+}
+
+func Test_ValidateAminoJSON_Valid(t *testing.T) {
+	assert.NoError(t, ValidateAminoJSON(getDummyTx(), DeviceModelNanoS))
+
+	bz, err := StdSignDoc{
+		ChainID: "cosmoshub-4",
+		Memo:    `quote " and \ backslash`,
+		Msgs:    []json.RawMessage{json.RawMessage(`{"type":"a","value":{"n":-1.5e3,"ok":true,"x":null}}`)},
+	}.Marshal()
+	require.NoError(t, err)
+	assert.NoError(t, ValidateAminoJSON(bz, DeviceModelNanoX))
+}
+
+func Test_ValidateAminoJSON_Errors(t *testing.T) {
+	doc := string(getDummyTx())
+
+	whitespace := strings.Replace(doc, `"chain_id":`, `"chain_id": `, 1)
+	unsorted := strings.Replace(doc, `"memo":"MEMO","msgs":["SOMETHING"]`, `"msgs":["SOMETHING"],"memo":"MEMO"`, 1)
+	nonASCII := strings.Replace(doc, "MEMO", "MÉMO", 1)
+	badEscape := strings.Replace(doc, "MEMO", `ME\xMO`, 1)
+	badLiteral := strings.Replace(doc, `"gas":5`, `"gas":05`, 1)
+
+	cases := []struct {
+		name    string
+		payload string
+		offset  int
+		reason  string
+	}{
+		{"garbage prefix", "A" + doc, 0, "JSON object"},
+		{"whitespace", whitespace, strings.Index(whitespace, " "), "whitespace"},
+		{"unsorted", unsorted, strings.Index(unsorted, `"memo"`), "not sorted"},
+		{"non ascii", nonASCII, strings.Index(nonASCII, "É"), "non-ASCII"},
+		{"missing field", strings.Replace(doc, `"memo":"MEMO",`, ``, 1), 0, `"memo"`},
+		{"truncated", doc[:50], 50, "end of input"},
+		{"bad escape", badEscape, strings.Index(badEscape, `\`), "escape"},
+		{"bad literal", badLiteral, strings.Index(badLiteral, "05"), "literal"},
+		{"trailing data", doc + "{}", len(doc), "after the sign doc"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requireValidationError(t, ValidateAminoJSON([]byte(c.payload), DeviceModelNanoX), c.offset, c.reason)
+		})
+	}
+}
+
+func Test_ValidateAminoJSON_TokenLimit(t *testing.T) {
+	msgs := make([]json.RawMessage, 100)
+	for i := range msgs {
+		msgs[i] = json.RawMessage(`{"type":"t","value":{}}`)
+	}
+	bz, err := StdSignDoc{Msgs: msgs}.Marshal()
+	require.NoError(t, err)
+
+	// the envelope takes 15 tokens and every message 5, so the 257th token is the first key of the 49th message
+	assert.NoError(t, ValidateAminoJSON(bz, DeviceModelStax))
+
+	msgLen := len(`{"type":"t","value":{}},`)
+	err = ValidateAminoJSON(bz, DeviceModelNanoS)
+	requireValidationError(t, err, strings.Index(string(bz), `{"type"`)+48*msgLen+1, "Nano S")
+}
+
+func Test_SignSECP256K1RejectsInvalidAminoLocally(t *testing.T) {
+	device := fakeSigningUserApp(testSECP256K1Key(), nil)
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{Major: 2}}
+
+	message := append([]byte{'A'}, getDummyTx()...)
+	_, err := userApp.SignSECP256K1([]uint32{44, 118, 0, 0, 0}, message, 0)
+	requireValidationError(t, err, 0, "JSON object")
+	assert.Empty(t, device.Sent())
+
+	// textual payloads are not JSON and are not validated
+	_, err = userApp.SignSECP256K1([]uint32{44, 118, 0, 0, 0}, message, 1)
+	assert.NoError(t, err)
+}
+
+func Test_DeviceModelFromVersion(t *testing.T) {
+	device := newFakeDevice(func([]byte) ([]byte, error) {
+		return []byte{0, 2, 34, 12, 0, 0x31, 0x10, 0x00, 0x04}, nil
+	})
+	userApp := &LedgerCosmos{api: device}
+
+	assert.Equal(t, DeviceModelUnknown, userApp.DeviceModel())
+	_, err := userApp.GetVersion()
+	require.NoError(t, err)
+	assert.Equal(t, DeviceModelNanoS, userApp.DeviceModel())
+	assert.Equal(t, 256, userApp.DeviceModel().MaxJSONTokens())
+
+	assert.Equal(t, DeviceModelNanoSPlus, deviceModelFromTargetID(0x33100004))
+	assert.Equal(t, DeviceModelFlex, deviceModelFromTargetID(0x33300004))
+}
@@ -0,0 +1,147 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"golang.org/x/crypto/ripemd160" //nolint:staticcheck // required by the Cosmos address format
+)
+
+// ErrSignerMismatch is returned when the signer address does not belong to the public key
+var ErrSignerMismatch = errors.New("signer address does not match the public key")
+
+// msgSignData is the ADR-036 message, https://github.com/cosmos/cosmos-sdk/blob/main/docs/architecture/adr-036-arbitrary-signature.md
+type msgSignData struct {
+	Type  string `json:"type"`
+	Value struct {
+		Data   []byte `json:"data"`
+		Signer string `json:"signer"`
+	} `json:"value"`
+}
+
+// ArbitrarySignature is an ADR-036 signature of off-chain data
+type ArbitrarySignature struct {
+	Signer string
+	Data   []byte
+	// PubKey is the compressed secp256k1 public key of the signer
+	PubKey    []byte
+	Signature Signature
+}
+
+// Verify checks the signature offline, see VerifyArbitrary
+func (s ArbitrarySignature) Verify() error {
+	return VerifyArbitrary(s.PubKey, s.Signer, s.Data, s.Signature)
+}
+
+// ArbitrarySignDoc returns the amino sign bytes ADR-036 defines for data: a single sign/MsgSignData
+// message, an empty chain id, no fees and zero account number and sequence
+func ArbitrarySignDoc(signer string, data []byte) ([]byte, error) {
+	msg := msgSignData{Type: "sign/MsgSignData"}
+	msg.Value.Data = data
+	msg.Value.Signer = signer
+	if msg.Value.Data == nil {
+		msg.Value.Data = []byte{}
+	}
+
+	bz, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return StdSignDoc{Msgs: []json.RawMessage{bz}}.Marshal()
+}
+
+// SignArbitrary signs off-chain data following ADR-036. The signer must be the bech32 address
+// of bip32Path, it is checked against the device public key before anything is displayed.
+// this command requires user confirmation in the device
+func (ledger *LedgerCosmos) SignArbitrary(bip32Path []uint32, signer string, data []byte) (*ArbitrarySignature, error) {
+	pubkey, err := ledger.cachedPublicKeySECP256K1(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := signerHash(pubkey, signer); err != nil {
+		return nil, err
+	}
+
+	signDoc, err := ArbitrarySignDoc(signer, data)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ledger.SignAndVerify(bip32Path, signDoc, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArbitrarySignature{
+		Signer:    signer,
+		Data:      data,
+		PubKey:    pubkey,
+		Signature: signature,
+	}, nil
+}
+
+// VerifyArbitrary checks an ADR-036 signature without a device: signer must be the address of
+// pubkey, either a Cosmos or a coin type 60 address, and signature must cover the sign doc.
+func VerifyArbitrary(pubkey []byte, signer string, data []byte, signature Signature) error {
+	hash, err := signerHash(pubkey, signer)
+	if err != nil {
+		return err
+	}
+
+	signDoc, err := ArbitrarySignDoc(signer, data)
+	if err != nil {
+		return err
+	}
+	if !signature.verifyHash(pubkey, hash(signDoc)) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// signerHash checks that signer is derived from pubkey and returns the hash the device signs with
+func signerHash(pubkey []byte, signer string) (func([]byte) []byte, error) {
+	_, addr, err := bech32Decode(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(cosmosAddress(pubkey), addr) {
+		return func(bz []byte) []byte {
+			hash := sha256.Sum256(bz)
+			return hash[:]
+		}, nil
+	}
+
+	ethereum, err := EthAddressFromPubKey(pubkey)
+	if err == nil && bytes.Equal(ethereum[:], addr) {
+		return keccak256, nil
+	}
+
+	return nil, ErrSignerMismatch
+}
+
+// cosmosAddress returns ripemd160(sha256(pubkey)), the address of a coin type 118 key
+func cosmosAddress(pubkey []byte) []byte {
+	sha := sha256.Sum256(pubkey)
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return hasher.Sum(nil)
+}
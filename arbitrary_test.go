@@ -0,0 +1,88 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCosmosSigner(t *testing.T, pubkey []byte) string {
+	t.Helper()
+	signer, err := bech32Encode("cosmos", cosmosAddress(pubkey))
+	require.NoError(t, err)
+	return signer
+}
+
+func Test_ArbitrarySignDoc(t *testing.T) {
+	bz, err := ArbitrarySignDoc("cosmos1signer", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"account_number":"0","chain_id":"","fee":{"amount":[],"gas":"0"},"memo":"",`+
+		`"msgs":[{"type":"sign/MsgSignData","value":{"data":"aGVsbG8=","signer":"cosmos1signer"}}],"sequence":"0"}`, string(bz))
+	assert.NoError(t, ValidateAminoJSON(bz, DeviceModelNanoS))
+}
+
+func Test_SignArbitrary(t *testing.T) {
+	key := testSECP256K1Key()
+	pubkey := key.PubKey().SerializeCompressed()
+	device := fakeSigningUserApp(key, nil)
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{0, 2, 34, 12}}
+	signer := testCosmosSigner(t, pubkey)
+
+	result, err := userApp.SignArbitrary([]uint32{44, 118, 0, 0, 0}, signer, []byte("login nonce 42"))
+	require.NoError(t, err)
+	assert.Equal(t, pubkey, result.PubKey)
+	assert.NoError(t, result.Verify())
+
+	assert.ErrorIs(t, VerifyArbitrary(pubkey, signer, []byte("login nonce 43"), result.Signature), ErrSignatureMismatch)
+
+	other, err := bech32Encode("cosmos", make([]byte, 20))
+	require.NoError(t, err)
+	assert.ErrorIs(t, VerifyArbitrary(pubkey, other, result.Data, result.Signature), ErrSignerMismatch)
+}
+
+func Test_SignArbitraryWrongSigner(t *testing.T) {
+	device := fakeSigningUserApp(testSECP256K1Key(), nil)
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{0, 2, 34, 12}}
+
+	other, err := bech32Encode("cosmos", make([]byte, 20))
+	require.NoError(t, err)
+
+	_, err = userApp.SignArbitrary([]uint32{44, 118, 0, 0, 0}, other, []byte("data"))
+	assert.ErrorIs(t, err, ErrSignerMismatch)
+
+	// only the public key was requested, nothing was signed
+	for _, apdu := range device.Sent() {
+		assert.NotEqual(t, byte(userINSSignSECP256K1), apdu[1])
+	}
+}
+
+func Test_SignArbitraryCoinType60(t *testing.T) {
+	key := testSECP256K1Key()
+	userApp := &LedgerCosmos{api: fakeSigningUserApp(key, nil), version: VersionInfo{0, 2, 34, 12}}
+
+	addr, err := EthAddressFromPubKey(key.PubKey().SerializeCompressed())
+	require.NoError(t, err)
+	signer, err := addr.Bech32("evmos")
+	require.NoError(t, err)
+
+	result, err := userApp.SignArbitrary([]uint32{44, 60, 0, 0, 0}, signer, []byte("data"))
+	require.NoError(t, err)
+	assert.NoError(t, result.Verify())
+}
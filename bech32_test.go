@@ -0,0 +1,60 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Bech32Encode(t *testing.T) {
+	addr, err := bech32Encode("cosmos", make([]byte, 20))
+	require.NoError(t, err)
+	assert.Equal(t, "cosmos1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqnrql8a", addr)
+
+	_, err = bech32Encode("", []byte{1})
+	assert.Error(t, err)
+	_, err = bech32Encode("Cosmos", []byte{1})
+	assert.Error(t, err)
+}
+
+func Test_Bech32Decode(t *testing.T) {
+	// BIP-173 test vectors
+	for _, s := range []string{"A12UEL5L", "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw"} {
+		hrp, data, err := bech32Decode(s)
+		require.NoError(t, err, s)
+
+		encoded, err := bech32Encode(hrp, data)
+		require.NoError(t, err)
+		assert.Equal(t, strings.ToLower(s), encoded)
+	}
+
+	for _, s := range []string{
+		"A1G7SGD8",  // empty hrp and bad checksum
+		"10a06t8",   // empty hrp
+		"x1b4n0q5v", // invalid character
+		"li1dgmt3",  // too short checksum
+		"A12uEL5L",  // mixed case
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxx", // bad checksum
+	} {
+		_, _, err := bech32Decode(s)
+		assert.Error(t, err, s)
+	}
+}
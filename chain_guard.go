@@ -0,0 +1,94 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrChainIDMismatch is returned when a message is signed for another chain than the configured one
+	ErrChainIDMismatch = errors.New("sign bytes chain id does not match the configured chain")
+	// ErrMessageTypeNotAllowed is returned when a message type is not in the allowlist of a ChainGuard
+	ErrMessageTypeNotAllowed = errors.New("message type is not allowed")
+)
+
+// ChainGuard restricts a validator signer to the votes and proposals of a single chain.
+// The key of a validator is usually the same on every network, this makes sure a node
+// configured for another chain cannot obtain signatures.
+type ChainGuard struct {
+	signer  ValidatorSigner
+	chainID string
+	allowed map[SignedMsgType]bool
+}
+
+var _ ValidatorSigner = (*ChainGuard)(nil)
+
+// NewChainGuard wraps signer so that it only signs messages of chainID whose type is in allowed.
+// Without types, prevotes, precommits and proposals are allowed. Vote extensions are allowed
+// with precommits, which they are part of.
+func NewChainGuard(signer ValidatorSigner, chainID string, allowed ...SignedMsgType) (*ChainGuard, error) {
+	if chainID == "" {
+		return nil, errors.New("chain id is required")
+	}
+	if len(allowed) == 0 {
+		allowed = []SignedMsgType{PrevoteType, PrecommitType, ProposalType}
+	}
+
+	g := &ChainGuard{signer: signer, chainID: chainID, allowed: map[SignedMsgType]bool{}}
+	for _, msgType := range allowed {
+		switch msgType {
+		case PrevoteType, PrecommitType, ProposalType:
+			g.allowed[msgType] = true
+		default:
+			return nil, fmt.Errorf("unsupported message type %d", msgType)
+		}
+	}
+	return g, nil
+}
+
+// ChainID returns the chain the guard signs for
+func (g *ChainGuard) ChainID() string {
+	return g.chainID
+}
+
+func (g *ChainGuard) GetPublicKeyED25519(bip32Path []uint32) ([]byte, error) {
+	return g.signer.GetPublicKeyED25519(bip32Path)
+}
+
+// SignED25519 signs message if it is a canonical vote, proposal or vote extension of the
+// configured chain with an allowed type
+func (g *ChainGuard) SignED25519(bip32Path []uint32, message []byte) ([]byte, error) {
+	msg, err := decodeSignBytes(message)
+	if err != nil {
+		return nil, err
+	}
+
+	msgType := msg.Type
+	if msg.Extension {
+		msgType = PrecommitType
+	}
+	if !g.allowed[msgType] {
+		return nil, fmt.Errorf("%w: %d", ErrMessageTypeNotAllowed, msgType)
+	}
+	if msg.ChainID != g.chainID {
+		return nil, fmt.Errorf("%w: got %q, expected %q", ErrChainIDMismatch, msg.ChainID, g.chainID)
+	}
+
+	return g.signer.SignED25519(bip32Path, message)
+}
@@ -0,0 +1,88 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ChainGuardSigns(t *testing.T) {
+	key := testED25519Key()
+	guard, err := NewChainGuard(&LedgerTendermintValidator{api: fakeSigningValidatorApp(key, nil)}, "cosmoshub-4")
+	require.NoError(t, err)
+	assert.Equal(t, "cosmoshub-4", guard.ChainID())
+
+	messages := [][]byte{
+		Proposal{Height: 10, POLRound: -1, BlockID: testBlockID}.SignBytes("cosmoshub-4"),
+		testVote(PrevoteType, 10, 0).SignBytes("cosmoshub-4"),
+		testVote(PrecommitType, 10, 0).SignBytes("cosmoshub-4"),
+		VoteExtension{Height: 10}.SignBytes("cosmoshub-4"),
+	}
+	for _, message := range messages {
+		signature, err := guard.SignED25519([]uint32{44, 118, 0, 0, 0}, message)
+		require.NoError(t, err)
+		assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), message, signature))
+	}
+}
+
+func Test_ChainGuardRejectsOtherChains(t *testing.T) {
+	device := fakeSigningValidatorApp(testED25519Key(), nil)
+	guard, err := NewChainGuard(&LedgerTendermintValidator{api: device}, "cosmoshub-4")
+	require.NoError(t, err)
+
+	messages := [][]byte{
+		testVote(PrevoteType, 10, 0).SignBytes("theta-testnet-001"),
+		Proposal{Height: 10, POLRound: -1}.SignBytes("cosmoshub-3"),
+		VoteExtension{Height: 10}.SignBytes(""),
+	}
+	for _, message := range messages {
+		_, err := guard.SignED25519([]uint32{44, 118, 0, 0, 0}, message)
+		assert.ErrorIs(t, err, ErrChainIDMismatch)
+	}
+
+	_, err = guard.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrInvalidSignBytes)
+	assert.Empty(t, device.Sent())
+}
+
+func Test_ChainGuardAllowlist(t *testing.T) {
+	device := fakeSigningValidatorApp(testED25519Key(), nil)
+	guard, err := NewChainGuard(&LedgerTendermintValidator{api: device}, "cosmoshub-4", PrevoteType)
+	require.NoError(t, err)
+
+	_, err = guard.SignED25519([]uint32{44, 118, 0, 0, 0}, testVote(PrevoteType, 10, 0).SignBytes("cosmoshub-4"))
+	require.NoError(t, err)
+
+	refused := [][]byte{
+		testVote(PrecommitType, 10, 0).SignBytes("cosmoshub-4"),
+		VoteExtension{Height: 10}.SignBytes("cosmoshub-4"),
+		Proposal{Height: 10, POLRound: -1}.SignBytes("cosmoshub-4"),
+	}
+	for _, message := range refused {
+		_, err := guard.SignED25519([]uint32{44, 118, 0, 0, 0}, message)
+		assert.ErrorIs(t, err, ErrMessageTypeNotAllowed)
+	}
+
+	_, err = NewChainGuard(&LedgerTendermintValidator{}, "")
+	assert.ErrorContains(t, err, "chain id is required")
+	_, err = NewChainGuard(&LedgerTendermintValidator{}, "cosmoshub-4", SignedMsgType(7))
+	assert.ErrorContains(t, err, "unsupported message type 7")
+}
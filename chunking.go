@@ -0,0 +1,160 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"context"
+	"math"
+	"time"
+
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+// chunkFraming is how P1 and P2 describe the chunks of a command
+type chunkFraming int
+
+const (
+	// framingPacketIndex puts the 1-based packet index in P1 and the packet count in P2
+	framingPacketIndex chunkFraming = iota
+	// framingInitAddLast puts ledger_go.ChunkInit, ChunkAdd or ChunkLast in P1, P2 is left to the command
+	framingInitAddLast
+)
+
+// ChunkProgress reports a chunk acknowledged by the device
+type ChunkProgress struct {
+	// Chunk is the 1-based index of the chunk, out of Chunks
+	Chunk  int
+	Chunks int
+	// Sent is the number of payload bytes sent so far, out of Total
+	Sent  int
+	Total int
+}
+
+// ChunkError is returned when a chunked command fails. Its message is the one of Err,
+// Chunk tells how far the command went.
+type ChunkError struct {
+	// Chunk is the 1-based index of the chunk that failed, out of Chunks
+	Chunk  int
+	Chunks int
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// chunkedCommand describes an instruction whose payload is sent in several APDUs
+type chunkedCommand struct {
+	cla     byte
+	ins     byte
+	framing chunkFraming
+	// p2 is sent with every chunk when framed with framingInitAddLast
+	p2 byte
+	// chunkSize is the maximum size of the chunks after the first one
+	chunkSize int
+	// errorHandler turns the error and response of a failed Exchange into the returned error
+	errorHandler func(err error, response []byte) error
+	// confirmation, if set, receives the duration of the exchange of the last chunk, which
+	// includes the user confirmation on the device
+	confirmation *time.Duration
+}
+
+// exchangeChunks sends first, usually the derivation path, then data split in chunks, and returns
+// the response to the last chunk. ctx is checked before each chunk and progress, if set, is
+// called after each one.
+func exchangeChunks(ctx context.Context, device ledger_go.LedgerDevice, cmd chunkedCommand, first, data []byte, progress func(ChunkProgress)) ([]byte, error) {
+	chunks := splitChunks(first, data, cmd.chunkSize)
+	// Legacy framing sends the packet index and count in one byte each, so it cannot express more
+	// than 255 packets. Newer apps are sent init/add/last chunks, which have no such limit.
+	if cmd.framing == framingPacketIndex && len(chunks) > math.MaxUint8 {
+		return nil, ErrMessageTooLarge
+	}
+
+	total := len(first) + len(data)
+	sent := 0
+	var response []byte
+	for i, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, &ChunkError{Chunk: i + 1, Chunks: len(chunks), Err: err}
+		}
+
+		p1, p2 := chunkParams(cmd, i, len(chunks))
+		header := []byte{cmd.cla, cmd.ins, p1, p2, byte(len(chunk))}
+
+		var err error
+		start := time.Now()
+		response, err = device.Exchange(append(header, chunk...))
+		if cmd.confirmation != nil && i == len(chunks)-1 {
+			*cmd.confirmation = time.Since(start)
+		}
+		if err != nil {
+			if cmd.errorHandler != nil {
+				err = cmd.errorHandler(err, response)
+			}
+			return nil, &ChunkError{Chunk: i + 1, Chunks: len(chunks), Err: err}
+		}
+
+		sent += len(chunk)
+		if progress != nil {
+			progress(ChunkProgress{Chunk: i + 1, Chunks: len(chunks), Sent: sent, Total: total})
+		}
+	}
+	return response, nil
+}
+
+// splitChunks returns first followed by data in chunks of at most chunkSize bytes
+func splitChunks(first, data []byte, chunkSize int) [][]byte {
+	chunks := make([][]byte, 0, 1+(len(data)+chunkSize-1)/chunkSize)
+	chunks = append(chunks, first)
+	for len(data) > 0 {
+		size := min(chunkSize, len(data))
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return chunks
+}
+
+func chunkParams(cmd chunkedCommand, index, count int) (p1, p2 byte) {
+	if cmd.framing == framingPacketIndex {
+		return byte(index + 1), byte(count)
+	}
+
+	switch {
+	case index == 0:
+		return ledger_go.ChunkInit, cmd.p2
+	case index == count-1:
+		return ledger_go.ChunkLast, cmd.p2
+	default:
+		return ledger_go.ChunkAdd, cmd.p2
+	}
+}
+
+// SetChunkProgress calls progress after every chunk of a signing request is acknowledged by the device.
+// Pass nil to stop. It should be called before the app is used concurrently.
+func (ledger *LedgerCosmos) SetChunkProgress(progress func(ChunkProgress)) {
+	ledger.progress = progress
+}
+
+// SetChunkProgress calls progress after every chunk of a signing request is acknowledged by the device.
+// Pass nil to stop. It should be called before the app is used concurrently.
+func (ledger *LedgerTendermintValidator) SetChunkProgress(progress func(ChunkProgress)) {
+	ledger.progress = progress
+}
@@ -0,0 +1,170 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+func Test_SplitChunks(t *testing.T) {
+	chunks := splitChunks([]byte{0xaa}, bytes.Repeat([]byte{1}, 7), 3)
+	assert.Equal(t, [][]byte{{0xaa}, {1, 1, 1}, {1, 1, 1}, {1}}, chunks)
+
+	assert.Equal(t, [][]byte{{0xaa}}, splitChunks([]byte{0xaa}, nil, 3))
+}
+
+func Test_ExchangeChunksFraming(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		return []byte{apdu[2]}, nil
+	})
+	data := bytes.Repeat([]byte{1}, 5)
+
+	cmd := chunkedCommand{cla: 0x55, ins: 2, framing: framingPacketIndex, chunkSize: 2}
+	response, err := exchangeChunks(context.Background(), device, cmd, []byte{0xaa}, data, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{4}, response, "response to the last chunk")
+
+	cmd = chunkedCommand{cla: 0x55, ins: 2, framing: framingInitAddLast, p2: 1, chunkSize: 2}
+	_, err = exchangeChunks(context.Background(), device, cmd, []byte{0xaa}, data, nil)
+	require.NoError(t, err)
+
+	sent := device.Sent()
+	require.Len(t, sent, 8)
+	assert.Equal(t, []byte{0x55, 2, 1, 4, 1, 0xaa}, sent[0])
+	assert.Equal(t, []byte{0x55, 2, 2, 4, 2, 1, 1}, sent[1])
+	assert.Equal(t, []byte{0x55, 2, 4, 4, 1, 1}, sent[3])
+	assert.Equal(t, []byte{0x55, 2, ledger_go.ChunkInit, 1, 1, 0xaa}, sent[4])
+	assert.Equal(t, []byte{0x55, 2, ledger_go.ChunkAdd, 1, 2, 1, 1}, sent[5])
+	assert.Equal(t, []byte{0x55, 2, ledger_go.ChunkAdd, 1, 2, 1, 1}, sent[6])
+	assert.Equal(t, []byte{0x55, 2, ledger_go.ChunkLast, 1, 1, 1}, sent[7])
+}
+
+func Test_ExchangeChunksProgressAndCancel(t *testing.T) {
+	device := newFakeDevice(func([]byte) ([]byte, error) {
+		return nil, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var progress []ChunkProgress
+	cmd := chunkedCommand{cla: 0x55, ins: 2, framing: framingInitAddLast, chunkSize: 4}
+	_, err := exchangeChunks(ctx, device, cmd, []byte{0xaa, 0xbb}, make([]byte, 10), func(p ChunkProgress) {
+		progress = append(progress, p)
+		if p.Chunk == 2 {
+			cancel()
+		}
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	var chunkErr *ChunkError
+	require.ErrorAs(t, err, &chunkErr)
+	assert.Equal(t, 3, chunkErr.Chunk)
+	assert.Equal(t, 4, chunkErr.Chunks)
+	assert.Len(t, device.Sent(), 2, "nothing is sent once cancelled")
+	assert.Equal(t, []ChunkProgress{
+		{Chunk: 1, Chunks: 4, Sent: 2, Total: 12},
+		{Chunk: 2, Chunks: 4, Sent: 6, Total: 12},
+	}, progress)
+}
+
+func Test_ExchangeChunksErrors(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		if apdu[2] == 2 {
+			return []byte("details"), apduError(swDataInvalid)
+		}
+		return nil, nil
+	})
+
+	cmd := chunkedCommand{
+		cla:       0x55,
+		ins:       2,
+		framing:   framingPacketIndex,
+		chunkSize: 4,
+		errorHandler: func(err error, response []byte) error {
+			return deviceError(err)
+		},
+	}
+	_, err := exchangeChunks(context.Background(), device, cmd, nil, make([]byte, 8), nil)
+
+	var chunkErr *ChunkError
+	require.ErrorAs(t, err, &chunkErr)
+	assert.Equal(t, 2, chunkErr.Chunk)
+	var devErr *DeviceError
+	require.ErrorAs(t, err, &devErr)
+	assert.Equal(t, uint16(swDataInvalid), devErr.StatusWord)
+	assert.Equal(t, devErr.Error(), err.Error())
+
+	// P1 and P2 hold at most 255 packets
+	_, err = exchangeChunks(context.Background(), device, cmd, nil, make([]byte, 255*4), nil)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+}
+
+func Test_SetChunkProgress(t *testing.T) {
+	userApp := &LedgerCosmos{api: fakeSigningUserApp(testSECP256K1Key(), nil), version: VersionInfo{Major: 2}}
+	var userProgress []ChunkProgress
+	userApp.SetChunkProgress(func(p ChunkProgress) {
+		userProgress = append(userProgress, p)
+	})
+
+	_, err := userApp.SignSECP256K1([]uint32{44, 118, 0, 0, 0}, getDummyTx(), 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, userProgress)
+	last := userProgress[len(userProgress)-1]
+	assert.Equal(t, last.Chunks, last.Chunk)
+	assert.Equal(t, last.Total, last.Sent)
+
+	validatorApp := &LedgerTendermintValidator{api: fakeSigningValidatorApp(testED25519Key(), nil)}
+	var validatorProgress []ChunkProgress
+	validatorApp.SetChunkProgress(func(p ChunkProgress) {
+		validatorProgress = append(validatorProgress, p)
+	})
+
+	message := bytes.Repeat([]byte{1}, 2*validatorMessageChunkSize)
+	_, err = validatorApp.SignED25519([]uint32{44, 118, 0, 0, 0}, message)
+	require.NoError(t, err)
+	pathBytes, err := GetBip32bytesv1([]uint32{44, 118, 0, 0, 0}, 10)
+	require.NoError(t, err)
+	total := len(pathBytes) + len(message)
+	assert.Equal(t, []ChunkProgress{
+		{Chunk: 1, Chunks: 3, Sent: len(pathBytes), Total: total},
+		{Chunk: 2, Chunks: 3, Sent: len(pathBytes) + validatorMessageChunkSize, Total: total},
+		{Chunk: 3, Chunks: 3, Sent: total, Total: total},
+	}, validatorProgress)
+}
+
+func Test_SignContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	device := fakeSigningValidatorApp(testED25519Key(), nil)
+	validatorApp := &LedgerTendermintValidator{api: device, version: VersionInfo{Minor: 9}}
+	_, err := validatorApp.SignED25519Context(ctx, []uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, device.Sent())
+
+	userDevice := fakeSigningUserApp(testSECP256K1Key(), nil)
+	userApp := &LedgerCosmos{api: userDevice, version: VersionInfo{Major: 2}}
+	_, err = userApp.SignSECP256K1Context(ctx, []uint32{44, 118, 0, 0, 0}, getDummyTx(), 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, userDevice.Sent())
+}
@@ -19,8 +19,13 @@ package ledger_cosmos_go
 import (
 	"encoding/binary"
 	"fmt"
+
+	ledger_go "github.com/zondax/ledger-go"
 )
 
+// newLedgerAdmin returns the admin used to enumerate devices. It is replaced in tests.
+var newLedgerAdmin = ledger_go.NewLedgerAdmin
+
 // VersionInfo contains app version information
 type VersionInfo struct {
 	AppMode uint8
@@ -0,0 +1,71 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// aminoEd25519PubKeyPrefix is the amino prefix of tendermint/PubKeyEd25519 followed by the key length
+var aminoEd25519PubKeyPrefix = []byte{0x16, 0x24, 0xde, 0x64, 0x20}
+
+// GetConsensusPubKey retrieves the public key of bip32Path as a validator consensus key
+func (ledger *LedgerTendermintValidator) GetConsensusPubKey(bip32Path []uint32) (Ed25519PubKey, error) {
+	bz, err := ledger.GetPublicKeyED25519(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+	return NewEd25519PubKey(bz)
+}
+
+// HexAddress returns the consensus address as shown by CometBFT, upper case hex
+func (k Ed25519PubKey) HexAddress() string {
+	return strings.ToUpper(hex.EncodeToString(k.Address()))
+}
+
+// ValconsAddress returns the bech32 consensus address, e.g. cosmosvalcons1... for the cosmos prefix
+func (k Ed25519PubKey) ValconsAddress(prefix string) (string, error) {
+	if _, err := NewEd25519PubKey(k); err != nil {
+		return "", err
+	}
+	return bech32Encode(prefix+"valcons", k.Address())
+}
+
+// ValconsPubKey returns the legacy bech32 consensus public key, e.g. cosmosvalconspub1... for the
+// cosmos prefix, which wraps the amino encoding of the key
+func (k Ed25519PubKey) ValconsPubKey(prefix string) (string, error) {
+	if _, err := NewEd25519PubKey(k); err != nil {
+		return "", err
+	}
+	return bech32Encode(prefix+"valconspub", append(append([]byte{}, aminoEd25519PubKeyPrefix...), k...))
+}
+
+// PrivValidatorKeyJSON returns the public part of a CometBFT priv_validator_key.json,
+// {"address":...,"pub_key":{"type":"tendermint/PubKeyEd25519","value":...}}.
+// The private key never leaves the device, so priv_key is not included.
+func (k Ed25519PubKey) PrivValidatorKeyJSON() ([]byte, error) {
+	pubkey, err := k.AminoJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(struct {
+		Address string          `json:"address"`
+		PubKey  json.RawMessage `json:"pub_key"`
+	}{k.HexAddress(), pubkey}, "", "  ")
+}
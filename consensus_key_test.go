@@ -0,0 +1,83 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetConsensusPubKey(t *testing.T) {
+	key := testED25519Key()
+	validatorApp := &LedgerTendermintValidator{api: fakeSigningValidatorApp(key, nil)}
+
+	pubkey, err := validatorApp.GetConsensusPubKey([]uint32{44, 118, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, Ed25519PubKey(key.Public().(ed25519.PublicKey)), pubkey)
+}
+
+func Test_ConsensusAddresses(t *testing.T) {
+	pubkey := Ed25519PubKey(testED25519Key().Public().(ed25519.PublicKey))
+	hash := sha256.Sum256(pubkey)
+
+	assert.Equal(t, strings.ToUpper(hex.EncodeToString(hash[:20])), pubkey.HexAddress())
+
+	valcons, err := pubkey.ValconsAddress("cosmos")
+	require.NoError(t, err)
+	hrp, data, err := bech32Decode(valcons)
+	require.NoError(t, err)
+	assert.Equal(t, "cosmosvalcons", hrp)
+	assert.Equal(t, hash[:20], data)
+
+	valconspub, err := pubkey.ValconsPubKey("osmo")
+	require.NoError(t, err)
+	// every legacy ed25519 consensus public key starts with the amino prefix zcjduepq
+	assert.True(t, strings.HasPrefix(valconspub, "osmovalconspub1zcjduepq"), valconspub)
+	hrp, data, err = bech32Decode(valconspub)
+	require.NoError(t, err)
+	assert.Equal(t, "osmovalconspub", hrp)
+	assert.Equal(t, []byte(pubkey), data[len(aminoEd25519PubKeyPrefix):])
+
+	_, err = Ed25519PubKey{1, 2, 3}.ValconsAddress("cosmos")
+	assert.Error(t, err)
+	_, err = pubkey.ValconsAddress("Cosmos")
+	assert.Error(t, err)
+}
+
+func Test_PrivValidatorKeyJSON(t *testing.T) {
+	pubkey := Ed25519PubKey(testED25519Key().Public().(ed25519.PublicKey))
+
+	bz, err := pubkey.PrivValidatorKeyJSON()
+	require.NoError(t, err)
+
+	expected := `{
+  "address": "` + pubkey.HexAddress() + `",
+  "pub_key": {
+    "type": "tendermint/PubKeyEd25519",
+    "value": "` + base64.StdEncoding.EncodeToString(pubkey) + `"
+  }
+}`
+	assert.JSONEq(t, expected, string(bz))
+	assert.NotContains(t, string(bz), "priv_key")
+}
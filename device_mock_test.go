@@ -0,0 +1,231 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+// fakeDevice is an in-memory LedgerDevice that answers APDUs through a handler
+type fakeDevice struct {
+	mu      sync.Mutex
+	handler func(apdu []byte) ([]byte, error)
+	sent    [][]byte
+	closed  bool
+}
+
+func newFakeDevice(handler func(apdu []byte) ([]byte, error)) *fakeDevice {
+	return &fakeDevice{handler: handler}
+}
+
+func (d *fakeDevice) Exchange(command []byte) ([]byte, error) {
+	d.mu.Lock()
+	d.sent = append(d.sent, append([]byte{}, command...))
+	handler := d.handler
+	d.mu.Unlock()
+	return handler(command)
+}
+
+func (d *fakeDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return nil
+}
+
+func (d *fakeDevice) Sent() [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([][]byte{}, d.sent...)
+}
+
+func (d *fakeDevice) IsClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// fakeAdmin hands out devices produced by connect
+type fakeAdmin struct {
+	connect func() (ledger_go.LedgerDevice, error)
+}
+
+func (a *fakeAdmin) CountDevices() int {
+	return 1
+}
+
+func (a *fakeAdmin) ListDevices() ([]string, error) {
+	return []string{"fake"}, nil
+}
+
+func (a *fakeAdmin) Connect(int) (ledger_go.LedgerDevice, error) {
+	return a.connect()
+}
+
+// useFakeAdmin makes device enumeration go through connect for the duration of the test
+func useFakeAdmin(t *testing.T, connect func() (ledger_go.LedgerDevice, error)) {
+	t.Helper()
+	previous := newLedgerAdmin
+	newLedgerAdmin = func() ledger_go.LedgerAdmin {
+		return &fakeAdmin{connect: connect}
+	}
+	t.Cleanup(func() { newLedgerAdmin = previous })
+}
+
+// apduError builds the error ledger-go returns for a status word
+func apduError(sw uint16) error {
+	return errors.New(ledger_go.ErrorMessage(sw))
+}
+
+// appNameResponse builds the dashboard GET_APP_NAME answer for name
+func appNameResponse(name string) []byte {
+	response := []byte{1, byte(len(name))}
+	response = append(response, name...)
+	return append(response, 5, '1', '.', '0', '.', '0', 1, 0)
+}
+
+// pathCoinType reads the coin type of a serialized v2 path
+func pathCoinType(path []byte) uint32 {
+	return binary.LittleEndian.Uint32(path[4:8]) &^ 0x80000000
+}
+
+// fakeSigningUserApp emulates a Cosmos user app v2 that signs with key.
+// Coin type 60 paths get a keccak derived address and keccak256 signatures.
+// tamper, if set, can modify the signature before it is returned.
+func fakeSigningUserApp(key *btcec.PrivateKey, tamper func(Signature) Signature) *fakeDevice {
+	var payload []byte
+	var ethereum bool
+	return newFakeDevice(func(apdu []byte) ([]byte, error) {
+		switch apdu[1] {
+		case userINSGetVersion:
+			return []byte{0, 2, 34, 12}, nil
+		case userINSGetAddrSecp256k1:
+			hrpLen := int(apdu[5])
+			if pathCoinType(apdu[6+hrpLen:]) == CoinTypeEthereum {
+				addr, _ := EthAddressFromPubKey(key.PubKey().SerializeCompressed())
+				bech, _ := addr.Bech32(string(apdu[6 : 6+hrpLen]))
+				return append(key.PubKey().SerializeCompressed(), bech...), nil
+			}
+			return append(key.PubKey().SerializeCompressed(), "cosmos1xyz"...), nil
+		case userINSSignSECP256K1:
+			switch apdu[2] {
+			case ledger_go.ChunkInit:
+				payload = nil
+				ethereum = pathCoinType(apdu[5:]) == CoinTypeEthereum
+				return nil, nil
+			case ledger_go.ChunkAdd:
+				payload = append(payload, apdu[5:]...)
+				return nil, nil
+			default:
+				payload = append(payload, apdu[5:]...)
+				signature := testSign(key, payload)
+				if ethereum {
+					signature = Signature(ecdsa.Sign(key, keccak256(payload)).Serialize())
+				}
+				if tamper != nil {
+					signature = tamper(signature)
+				}
+				return signature, nil
+			}
+		}
+		return nil, apduError(swINSNotSupported)
+	})
+}
+
+// fakeChunkedValidatorPath is the init chunk expected by fakeChunkedValidatorApp, the path
+// 44'/118'/0'/0'/0' as a component count and ten little endian uint32, unused ones zero
+var fakeChunkedValidatorPath = append([]byte{
+	5,
+	0x2c, 0x00, 0x00, 0x80,
+	0x76, 0x00, 0x00, 0x80,
+	0x00, 0x00, 0x00, 0x80,
+	0x00, 0x00, 0x00, 0x80,
+	0x00, 0x00, 0x00, 0x80,
+}, make([]byte, 5*4)...)
+
+// fakeChunkedValidatorApp emulates a validator app of the given major version, which signs
+// with the init/add/last chunking. It only accepts the path 44'/118'/0'/0'/0'.
+func fakeChunkedValidatorApp(key ed25519.PrivateKey, major byte) *fakeDevice {
+	var message []byte
+	return newFakeDevice(func(apdu []byte) ([]byte, error) {
+		switch apdu[1] {
+		case validatorINSGetVersion:
+			return []byte{0, major, 0, 0}, nil
+		case validatorINSPublicKeyED25519:
+			return key.Public().(ed25519.PublicKey), nil
+		case validatorINSSignED25519:
+			if apdu[3] != 0 {
+				return nil, apduError(swInvalidP1P2)
+			}
+			switch apdu[2] {
+			case ledger_go.ChunkInit:
+				if !bytes.Equal(apdu[4:], append([]byte{byte(len(fakeChunkedValidatorPath))}, fakeChunkedValidatorPath...)) {
+					return nil, apduError(swDataInvalid)
+				}
+				message = nil
+				return nil, nil
+			case ledger_go.ChunkAdd:
+				message = append(message, apdu[5:]...)
+				return nil, nil
+			case ledger_go.ChunkLast:
+				message = append(message, apdu[5:]...)
+				return ed25519.Sign(key, message), nil
+			}
+			return nil, apduError(swInvalidP1P2)
+		}
+		return nil, apduError(swINSNotSupported)
+	})
+}
+
+// fakeSigningValidatorApp emulates the Tendermint validator app signing with key.
+// tamper, if set, can modify the signature before it is returned.
+func fakeSigningValidatorApp(key ed25519.PrivateKey, tamper func([]byte) []byte) *fakeDevice {
+	var message []byte
+	return newFakeDevice(func(apdu []byte) ([]byte, error) {
+		switch apdu[1] {
+		case validatorINSGetVersion:
+			return []byte{0, 0, 9, 0}, nil
+		case validatorINSPublicKeyED25519:
+			return key.Public().(ed25519.PublicKey), nil
+		case validatorINSSignED25519:
+			packetIndex, packetCount := apdu[2], apdu[3]
+			if packetIndex == 1 {
+				message = nil
+			} else {
+				message = append(message, apdu[5:]...)
+			}
+			if packetIndex < packetCount {
+				return nil, nil
+			}
+			signature := ed25519.Sign(key, message)
+			if tamper != nil {
+				signature = tamper(signature)
+			}
+			return signature, nil
+		}
+		return nil, apduError(swINSNotSupported)
+	})
+}
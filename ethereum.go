@@ -0,0 +1,139 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// CoinTypeCosmos is the BIP44 coin type of Cosmos SDK keys, m/44'/118'
+	CoinTypeCosmos = 118
+	// CoinTypeEthereum is the BIP44 coin type used by Ethermint based chains, m/44'/60'
+	CoinTypeEthereum = 60
+)
+
+// EthereumMinVersion is the first Cosmos app version that derives and signs with coin type 60 paths.
+// These paths are only accepted while the device is in expert mode.
+var EthereumMinVersion = VersionInfo{0, 2, 34, 12}
+
+// ErrAddressMismatch is returned when the address shown by the device does not match its public key
+var ErrAddressMismatch = errors.New("address returned by the device does not match its public key")
+
+// EthAddress is the address of a coin type 60 key: the last 20 bytes of keccak256 of the public key
+type EthAddress [20]byte
+
+// EthAddressFromPubKey derives the address of a compressed or uncompressed secp256k1 public key
+func EthAddressFromPubKey(pubkey []byte) (EthAddress, error) {
+	var addr EthAddress
+
+	pub, err := btcec.ParsePubKey(pubkey)
+	if err != nil {
+		return addr, err
+	}
+
+	// the hash covers X||Y, without the 0x04 prefix of the uncompressed form
+	hash := keccak256(pub.SerializeUncompressed()[1:])
+	copy(addr[:], hash[12:])
+	return addr, nil
+}
+
+// Hex returns the EIP-55 checksummed 0x form of the address
+func (a EthAddress) Hex() string {
+	lower := hex.EncodeToString(a[:])
+	hash := keccak256([]byte(lower))
+
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksummed)
+}
+
+// Bech32 returns the address in the bech32 form used by the chain, e.g. evmos1...
+func (a EthAddress) Bech32(hrp string) (string, error) {
+	return bech32Encode(hrp, a[:])
+}
+
+func keccak256(data []byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// coinType returns the coin type of a BIP44 path, ignoring the hardening bit
+func coinType(bip32Path []uint32) uint32 {
+	if len(bip32Path) < 2 {
+		return 0
+	}
+	return bip32Path[1] &^ 0x80000000
+}
+
+// SupportsCoinType reports whether the app, as of the last GetVersion call, can use keys of coinType
+func (ledger *LedgerCosmos) SupportsCoinType(coinType uint32) bool {
+	if coinType != CoinTypeEthereum {
+		return true
+	}
+	return CheckVersion(ledger.version, EthereumMinVersion) == nil
+}
+
+// checkCoinType refuses paths the app version cannot derive
+func (ledger *LedgerCosmos) checkCoinType(bip32Path []uint32) error {
+	if !ledger.SupportsCoinType(coinType(bip32Path)) {
+		return NewVersionRequiredError(EthereumMinVersion, ledger.version)
+	}
+	return nil
+}
+
+// GetEthAddressSECP256K1 returns the pubkey (compressed) and address of a coin type 60 path.
+// The bech32 address displayed by the device is checked against the public key.
+// this command requires user confirmation in the device
+func (ledger *LedgerCosmos) GetEthAddressSECP256K1(bip32Path []uint32, hrp string) (pubkey []byte, addr EthAddress, err error) {
+	if coinType(bip32Path) != CoinTypeEthereum {
+		return nil, addr, fmt.Errorf("path %v does not use coin type %d", bip32Path, CoinTypeEthereum)
+	}
+
+	pubkey, shown, err := ledger.getAddressPubKeySECP256K1(bip32Path, hrp, true)
+	if err != nil {
+		return nil, addr, err
+	}
+
+	addr, err = EthAddressFromPubKey(pubkey)
+	if err != nil {
+		return nil, addr, err
+	}
+
+	expected, err := addr.Bech32(hrp)
+	if err != nil {
+		return nil, addr, err
+	}
+	if shown != expected {
+		return nil, addr, ErrAddressMismatch
+	}
+	return pubkey, addr, nil
+}
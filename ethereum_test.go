@@ -0,0 +1,121 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EthAddressFromPubKey(t *testing.T) {
+	one := make([]byte, 32)
+	one[31] = 1
+	key, _ := btcec.PrivKeyFromBytes(one)
+
+	addr, err := EthAddressFromPubKey(key.PubKey().SerializeCompressed())
+	require.NoError(t, err)
+	assert.Equal(t, "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf", addr.Hex())
+
+	uncompressed, err := EthAddressFromPubKey(key.PubKey().SerializeUncompressed())
+	require.NoError(t, err)
+	assert.Equal(t, addr, uncompressed)
+
+	_, err = EthAddressFromPubKey([]byte{1, 2, 3})
+	assert.Error(t, err)
+}
+
+func Test_EthAddressHexChecksum(t *testing.T) {
+	// EIP-55 test vectors
+	for _, expected := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		var addr EthAddress
+		bz, err := hex.DecodeString(strings.ToLower(expected[2:]))
+		require.NoError(t, err)
+		copy(addr[:], bz)
+		assert.Equal(t, expected, addr.Hex())
+	}
+}
+
+func Test_GetEthAddressSECP256K1(t *testing.T) {
+	key := testSECP256K1Key()
+	userApp := &LedgerCosmos{api: fakeSigningUserApp(key, nil), version: VersionInfo{0, 2, 34, 12}}
+	path := []uint32{44, 60, 0, 0, 0}
+
+	pubkey, addr, err := userApp.GetEthAddressSECP256K1(path, "evmos")
+	require.NoError(t, err)
+	assert.Equal(t, key.PubKey().SerializeCompressed(), pubkey)
+
+	expected, err := EthAddressFromPubKey(pubkey)
+	require.NoError(t, err)
+	assert.Equal(t, expected, addr)
+
+	bech, err := addr.Bech32("evmos")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(bech, "evmos1"))
+
+	_, _, err = userApp.GetEthAddressSECP256K1([]uint32{44, 118, 0, 0, 0}, "evmos")
+	assert.Error(t, err)
+}
+
+func Test_GetEthAddressSECP256K1Mismatch(t *testing.T) {
+	key := testSECP256K1Key()
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		return append(key.PubKey().SerializeCompressed(), "evmos1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqv2pyf9"...), nil
+	})
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{0, 2, 34, 12}}
+
+	_, _, err := userApp.GetEthAddressSECP256K1([]uint32{44, 60, 0, 0, 0}, "evmos")
+	assert.ErrorIs(t, err, ErrAddressMismatch)
+}
+
+func Test_CoinType60RequiresVersion(t *testing.T) {
+	device := fakeSigningUserApp(testSECP256K1Key(), nil)
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{0, 2, 34, 11}}
+	path := []uint32{44, 60, 0, 0, 0}
+
+	assert.True(t, userApp.SupportsCoinType(CoinTypeCosmos))
+	assert.False(t, userApp.SupportsCoinType(CoinTypeEthereum))
+
+	var versionErr *VersionRequiredError
+	_, _, err := userApp.GetAddressPubKeySECP256K1(path, "evmos")
+	assert.ErrorAs(t, err, &versionErr)
+	_, err = userApp.SignSECP256K1(path, getDummyTx(), 0)
+	assert.ErrorAs(t, err, &versionErr)
+	assert.Empty(t, device.Sent())
+
+	userApp.version = VersionInfo{0, 3, 0, 0}
+	assert.True(t, userApp.SupportsCoinType(CoinTypeEthereum))
+}
+
+func Test_SignAndVerifyCoinType60(t *testing.T) {
+	key := testSECP256K1Key()
+	userApp := &LedgerCosmos{api: fakeSigningUserApp(key, nil), version: VersionInfo{0, 2, 34, 12}}
+
+	signature, err := userApp.SignAndVerify([]uint32{44, 60, 0, 0, 0}, getDummyTx(), 0)
+	require.NoError(t, err)
+	assert.True(t, signature.VerifyKeccak256(key.PubKey().SerializeCompressed(), getDummyTx()))
+	assert.False(t, signature.Verify(key.PubKey().SerializeCompressed(), getDummyTx()))
+}
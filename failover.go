@@ -0,0 +1,336 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoHealthySigner is returned when every device of a FailoverSigner failed
+	ErrNoHealthySigner = errors.New("no healthy validator device")
+	// ErrFailoverKeyMismatch is returned for a backup device whose key differs from the primary
+	ErrFailoverKeyMismatch = errors.New("backup device returned a different public key")
+)
+
+// FailoverEventType identifies a FailoverEvent
+type FailoverEventType int
+
+const (
+	// FailoverEventDeviceFailed is emitted when a device fails with a transport error or timeout
+	FailoverEventDeviceFailed FailoverEventType = iota
+	// FailoverEventKeyMismatch is emitted when a backup device returns a different public key
+	FailoverEventKeyMismatch
+	// FailoverEventSwitched is emitted when another device becomes the active one
+	FailoverEventSwitched
+)
+
+func (t FailoverEventType) String() string {
+	switch t {
+	case FailoverEventDeviceFailed:
+		return "device failed"
+	case FailoverEventKeyMismatch:
+		return "key mismatch"
+	case FailoverEventSwitched:
+		return "switched"
+	default:
+		return fmt.Sprintf("FailoverEventType(%d)", int(t))
+	}
+}
+
+// FailoverEvent reports a device failure or a switch of a FailoverSigner
+type FailoverEvent struct {
+	Type FailoverEventType
+	Time time.Time
+	// Device is the index of the device that failed or became active
+	Device int
+	// Previous is the index of the previously active device of a switch
+	Previous int
+	// Err is the failure of the device
+	Err error
+}
+
+// SignerHealth is the status of one device of a FailoverSigner
+type SignerHealth struct {
+	Active  bool
+	Healthy bool
+	// Err is the last failure of the device, nil while it is healthy
+	Err error
+	// Since is when Healthy last changed
+	Since time.Time
+}
+
+// FailoverOptions configures a FailoverSigner
+type FailoverOptions struct {
+	// StateFile keeps the sign state shared by all devices, see StateSigner
+	StateFile string
+	// BIP32Path is the path of the consensus key, whose public key must be the same on every device.
+	// Defaults to 44'/118'/0'/0'/0'.
+	BIP32Path []uint32
+	// Timeout, if set, treats a device that takes longer to answer as failed
+	Timeout time.Duration
+	// Events, if set, receives failover events. Events are dropped if the channel is full.
+	Events chan<- FailoverEvent
+}
+
+// FailoverSigner signs with the first of several devices holding the same key and switches to
+// the next one after a transport failure or timeout. A backup is only used once it returned the
+// public key of the primary, and all devices share one StateSigner so that a switch can never
+// lead to a double sign.
+type FailoverSigner struct {
+	signers []ValidatorSigner
+	opts    FailoverOptions
+	state   *StateSigner
+	pubkey  []byte
+
+	// callMu serializes device calls, the active device only changes while it is held
+	callMu sync.Mutex
+
+	mu     sync.Mutex
+	active int
+	health []SignerHealth
+}
+
+var _ ValidatorSigner = (*FailoverSigner)(nil)
+
+// failoverDevices is the signer guarded by the shared state
+type failoverDevices struct {
+	f *FailoverSigner
+}
+
+func (d failoverDevices) GetPublicKeyED25519(bip32Path []uint32) ([]byte, error) {
+	return d.f.do(func(signer ValidatorSigner) ([]byte, error) {
+		return signer.GetPublicKeyED25519(bip32Path)
+	})
+}
+
+func (d failoverDevices) SignED25519(bip32Path []uint32, message []byte) ([]byte, error) {
+	return d.f.do(func(signer ValidatorSigner) ([]byte, error) {
+		return signer.SignED25519(bip32Path, message)
+	})
+}
+
+// NewFailoverSigner returns a signer using signers in order, usually *LedgerTendermintValidator
+// with the primary device first. The first device that answers becomes active and its public key
+// is the one the others must match.
+func NewFailoverSigner(signers []ValidatorSigner, opts FailoverOptions) (*FailoverSigner, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("at least one validator signer is required")
+	}
+	if opts.StateFile == "" {
+		return nil, errors.New("a state file is required")
+	}
+	if opts.BIP32Path == nil {
+		opts.BIP32Path = []uint32{44, 118, 0, 0, 0}
+	}
+
+	now := time.Now()
+	f := &FailoverSigner{
+		signers: signers,
+		opts:    opts,
+		active:  -1,
+		health:  make([]SignerHealth, len(signers)),
+	}
+	for i := range f.health {
+		f.health[i] = SignerHealth{Healthy: true, Since: now}
+	}
+
+	var errs []error
+	for i, signer := range signers {
+		pubkey, err := f.call(signer, func(signer ValidatorSigner) ([]byte, error) {
+			return signer.GetPublicKeyED25519(opts.BIP32Path)
+		})
+		if err != nil {
+			f.setFailed(i, err)
+			errs = append(errs, fmt.Errorf("device %d: %w", i, err))
+			continue
+		}
+		f.pubkey = pubkey
+		f.active = i
+		f.health[i].Active = true
+		break
+	}
+	if f.active < 0 {
+		return nil, fmt.Errorf("%w: %w", ErrNoHealthySigner, errors.Join(errs...))
+	}
+
+	state, err := NewStateSigner(failoverDevices{f}, opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	f.state = state
+	return f, nil
+}
+
+// GetPublicKeyED25519 retrieves the public key from the active device
+func (f *FailoverSigner) GetPublicKeyED25519(bip32Path []uint32) ([]byte, error) {
+	return f.state.GetPublicKeyED25519(bip32Path)
+}
+
+// SignED25519 checks message against the shared sign state and signs it with the active device,
+// failing over to the next healthy one
+func (f *FailoverSigner) SignED25519(bip32Path []uint32, message []byte) ([]byte, error) {
+	return f.state.SignED25519(bip32Path, message)
+}
+
+// State returns the last signed height, round and step shared by the devices
+func (f *FailoverSigner) State() SignState {
+	return f.state.State()
+}
+
+// Active returns the index of the device currently used
+func (f *FailoverSigner) Active() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// Health returns the status of every device, in the order they were given
+func (f *FailoverSigner) Health() []SignerHealth {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SignerHealth{}, f.health...)
+}
+
+// do runs op on the active device and on the next ones while they fail with a transport error
+func (f *FailoverSigner) do(op func(signer ValidatorSigner) ([]byte, error)) ([]byte, error) {
+	f.callMu.Lock()
+	defer f.callMu.Unlock()
+
+	active := f.Active()
+	var errs []error
+	for attempt := 0; attempt < len(f.signers); attempt++ {
+		i := (active + attempt) % len(f.signers)
+		if i != active {
+			if err := f.verifyBackup(i); err != nil {
+				errs = append(errs, fmt.Errorf("device %d: %w", i, err))
+				continue
+			}
+			f.switchTo(i)
+		}
+
+		result, err := f.call(f.signers[i], op)
+		if err == nil {
+			f.setHealthy(i)
+			return result, nil
+		}
+		if !isFailoverError(err) {
+			return nil, err
+		}
+		f.setFailed(i, err)
+		f.emit(FailoverEvent{Type: FailoverEventDeviceFailed, Device: i, Err: err})
+		errs = append(errs, fmt.Errorf("device %d: %w", i, err))
+	}
+	return nil, fmt.Errorf("%w: %w", ErrNoHealthySigner, errors.Join(errs...))
+}
+
+// verifyBackup checks that device i holds the same key as the primary
+func (f *FailoverSigner) verifyBackup(i int) error {
+	pubkey, err := f.call(f.signers[i], func(signer ValidatorSigner) ([]byte, error) {
+		return signer.GetPublicKeyED25519(f.opts.BIP32Path)
+	})
+	if err != nil {
+		f.setFailed(i, err)
+		f.emit(FailoverEvent{Type: FailoverEventDeviceFailed, Device: i, Err: err})
+		return err
+	}
+	if !bytes.Equal(pubkey, f.pubkey) {
+		f.setFailed(i, ErrFailoverKeyMismatch)
+		f.emit(FailoverEvent{Type: FailoverEventKeyMismatch, Device: i, Err: ErrFailoverKeyMismatch})
+		return ErrFailoverKeyMismatch
+	}
+	return nil
+}
+
+func (f *FailoverSigner) switchTo(i int) {
+	f.mu.Lock()
+	previous := f.active
+	f.active = i
+	f.health[previous].Active = false
+	f.health[i].Active = true
+	f.mu.Unlock()
+
+	f.emit(FailoverEvent{Type: FailoverEventSwitched, Device: i, Previous: previous})
+}
+
+// call runs op on signer, giving up after the timeout. The device keeps processing a command
+// that timed out, it is only abandoned.
+func (f *FailoverSigner) call(signer ValidatorSigner, op func(signer ValidatorSigner) ([]byte, error)) ([]byte, error) {
+	if f.opts.Timeout <= 0 {
+		return op(signer)
+	}
+
+	type result struct {
+		response []byte
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := op(signer)
+		done <- result{response, err}
+	}()
+
+	timer := time.NewTimer(f.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-timer.C:
+		return nil, ErrExchangeTimeout
+	}
+}
+
+func (f *FailoverSigner) setHealthy(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.health[i].Healthy {
+		f.health[i] = SignerHealth{Active: f.health[i].Active, Healthy: true, Since: time.Now()}
+	}
+}
+
+func (f *FailoverSigner) setFailed(i int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.health[i].Healthy {
+		f.health[i].Since = time.Now()
+	}
+	f.health[i].Healthy = false
+	f.health[i].Err = err
+}
+
+func (f *FailoverSigner) emit(event FailoverEvent) {
+	if f.opts.Events == nil {
+		return
+	}
+	event.Time = time.Now()
+	select {
+	case f.opts.Events <- event:
+	default:
+	}
+}
+
+// isFailoverError returns true for timeouts and transport failures of the device. Other errors,
+// such as a refused message or a signature that does not verify, stop signing instead.
+func isFailoverError(err error) bool {
+	return errors.Is(err, ErrExchangeTimeout) ||
+		errors.Is(err, ErrDeviceDisconnected) ||
+		isTransportError(err)
+}
@@ -0,0 +1,247 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errTestTransport is a transport failure as returned by the validator app
+var errTestTransport = deviceError(errors.New("hidapi: device disconnected"))
+
+// testSigner lets tests break a validator signer
+type testSigner struct {
+	ValidatorSigner
+
+	mu    sync.Mutex
+	err   error
+	delay time.Duration
+	signs int
+}
+
+func newTestSigner(key ed25519.PrivateKey) *testSigner {
+	return &testSigner{ValidatorSigner: &LedgerTendermintValidator{api: fakeSigningValidatorApp(key, nil)}}
+}
+
+func (s *testSigner) fail(err error, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err, s.delay = err, delay
+}
+
+func (s *testSigner) broken() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delay, s.err
+}
+
+func (s *testSigner) Signs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signs
+}
+
+func (s *testSigner) GetPublicKeyED25519(bip32Path []uint32) ([]byte, error) {
+	delay, err := s.broken()
+	time.Sleep(delay)
+	if err != nil {
+		return nil, err
+	}
+	return s.ValidatorSigner.GetPublicKeyED25519(bip32Path)
+}
+
+func (s *testSigner) SignED25519(bip32Path []uint32, message []byte) ([]byte, error) {
+	delay, err := s.broken()
+	time.Sleep(delay)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.signs++
+	s.mu.Unlock()
+	return s.ValidatorSigner.SignED25519(bip32Path, message)
+}
+
+func newTestFailover(t *testing.T, opts FailoverOptions, signers ...*testSigner) (*FailoverSigner, chan FailoverEvent) {
+	events := make(chan FailoverEvent, 16)
+	opts.StateFile = filepath.Join(t.TempDir(), "sign_state.json")
+	opts.Events = events
+
+	validatorSigners := make([]ValidatorSigner, len(signers))
+	for i, signer := range signers {
+		validatorSigners[i] = signer
+	}
+	failover, err := NewFailoverSigner(validatorSigners, opts)
+	require.NoError(t, err)
+	return failover, events
+}
+
+func Test_FailoverOnTransportError(t *testing.T) {
+	key := testED25519Key()
+	primary, backup := newTestSigner(key), newTestSigner(key)
+	failover, events := newTestFailover(t, FailoverOptions{}, primary, backup)
+	path := []uint32{44, 118, 0, 0, 0}
+
+	_, err := failover.SignED25519(path, testVote(PrevoteType, 10, 0).SignBytes("test-chain"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, failover.Active())
+
+	primary.fail(errTestTransport, 0)
+	message := testVote(PrecommitType, 10, 0).SignBytes("test-chain")
+	signature, err := failover.SignED25519(path, message)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), message, signature))
+	assert.Equal(t, 1, failover.Active())
+	assert.Equal(t, 1, backup.Signs())
+
+	failed := <-events
+	assert.Equal(t, FailoverEventDeviceFailed, failed.Type)
+	assert.Equal(t, 0, failed.Device)
+	assert.ErrorIs(t, failed.Err, errTestTransport)
+	switched := <-events
+	assert.Equal(t, FailoverEventSwitched, switched.Type)
+	assert.Equal(t, 1, switched.Device)
+	assert.Equal(t, 0, switched.Previous)
+
+	health := failover.Health()
+	require.Len(t, health, 2)
+	assert.False(t, health[0].Healthy)
+	assert.False(t, health[0].Active)
+	assert.ErrorIs(t, health[0].Err, errTestTransport)
+	assert.True(t, health[1].Healthy)
+	assert.True(t, health[1].Active)
+
+	// the backup stays active once the primary is back
+	primary.fail(nil, 0)
+	_, err = failover.SignED25519(path, testVote(PrevoteType, 11, 0).SignBytes("test-chain"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failover.Active())
+}
+
+func Test_FailoverSharesState(t *testing.T) {
+	key := testED25519Key()
+	primary, backup := newTestSigner(key), newTestSigner(key)
+	failover, _ := newTestFailover(t, FailoverOptions{}, primary, backup)
+	path := []uint32{44, 118, 0, 0, 0}
+
+	precommit := testVote(PrecommitType, 10, 0)
+	_, err := failover.SignED25519(path, precommit.SignBytes("test-chain"))
+	require.NoError(t, err)
+
+	primary.fail(errTestTransport, 0)
+	conflicting := precommit
+	conflicting.BlockID = BlockID{}
+	_, err = failover.SignED25519(path, conflicting.SignBytes("test-chain"))
+	assert.ErrorIs(t, err, ErrConflictingSignBytes)
+	_, err = failover.SignED25519(path, testVote(PrevoteType, 9, 0).SignBytes("test-chain"))
+	assert.ErrorIs(t, err, ErrHRSRegression)
+	assert.Zero(t, backup.Signs())
+
+	// the identical precommit can be signed again by the backup
+	_, err = failover.SignED25519(path, precommit.SignBytes("test-chain"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, backup.Signs())
+	assert.Equal(t, int64(10), failover.State().Height)
+}
+
+func Test_FailoverKeyMismatch(t *testing.T) {
+	primary, backup := newTestSigner(testED25519Key()), newTestSigner(testConnKey("another seed"))
+	failover, events := newTestFailover(t, FailoverOptions{}, primary, backup)
+
+	primary.fail(errTestTransport, 0)
+	_, err := failover.SignED25519([]uint32{44, 118, 0, 0, 0}, testVote(PrevoteType, 10, 0).SignBytes("test-chain"))
+	assert.ErrorIs(t, err, ErrNoHealthySigner)
+	assert.ErrorIs(t, err, ErrFailoverKeyMismatch)
+	assert.Zero(t, backup.Signs())
+	assert.Equal(t, 0, failover.Active())
+
+	assert.Equal(t, FailoverEventDeviceFailed, (<-events).Type)
+	mismatch := <-events
+	assert.Equal(t, FailoverEventKeyMismatch, mismatch.Type)
+	assert.Equal(t, 1, mismatch.Device)
+}
+
+func Test_FailoverOnTimeout(t *testing.T) {
+	key := testED25519Key()
+	primary, backup := newTestSigner(key), newTestSigner(key)
+	failover, _ := newTestFailover(t, FailoverOptions{Timeout: 50 * time.Millisecond}, primary, backup)
+
+	primary.fail(nil, time.Second)
+	_, err := failover.SignED25519([]uint32{44, 118, 0, 0, 0}, testVote(PrevoteType, 10, 0).SignBytes("test-chain"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failover.Active())
+	assert.ErrorIs(t, failover.Health()[0].Err, ErrExchangeTimeout)
+}
+
+func Test_FailoverKeepsMessageErrors(t *testing.T) {
+	key := testED25519Key()
+	primary, backup := newTestSigner(key), newTestSigner(key)
+	failover, _ := newTestFailover(t, FailoverOptions{}, primary, backup)
+
+	causes := []error{
+		ErrSignRefused,
+		ErrSignatureMismatch,
+		errors.New("invalid signature length"),
+		deviceError(errors.New("APDU[data length] mismatch")),
+	}
+	for i, cause := range causes {
+		primary.fail(cause, 0)
+		_, err := failover.SignED25519([]uint32{44, 118, 0, 0, 0}, testVote(PrevoteType, int64(10+i), 0).SignBytes("test-chain"))
+		assert.ErrorIs(t, err, cause)
+		assert.Equal(t, 0, failover.Active())
+		assert.Zero(t, backup.Signs())
+		assert.True(t, failover.Health()[0].Healthy)
+	}
+}
+
+func Test_NewFailoverSigner(t *testing.T) {
+	key := testED25519Key()
+	primary, backup := newTestSigner(key), newTestSigner(key)
+	primary.fail(errTestTransport, 0)
+
+	// the backup is used when the primary is missing at startup
+	failover, _ := newTestFailover(t, FailoverOptions{}, primary, backup)
+	assert.Equal(t, 1, failover.Active())
+	assert.False(t, failover.Health()[0].Healthy)
+
+	pubkey, err := failover.GetPublicKeyED25519([]uint32{44, 118, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(key.Public().(ed25519.PublicKey)), pubkey)
+
+	backup.fail(errTestTransport, 0)
+	_, err = NewFailoverSigner([]ValidatorSigner{primary, backup}, FailoverOptions{StateFile: filepath.Join(t.TempDir(), "state.json")})
+	assert.ErrorIs(t, err, ErrNoHealthySigner)
+
+	_, err = NewFailoverSigner(nil, FailoverOptions{})
+	assert.Error(t, err)
+
+	_, err = NewFailoverSigner([]ValidatorSigner{newTestSigner(key)}, FailoverOptions{})
+	assert.EqualError(t, err, "a state file is required")
+}
+
+func Test_FailoverEventTypeString(t *testing.T) {
+	assert.Equal(t, "switched", FailoverEventSwitched.String())
+	assert.Equal(t, "FailoverEventType(9)", FailoverEventType(9).String())
+}
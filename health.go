@@ -0,0 +1,255 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HealthStatus is the result of a validator device health check
+type HealthStatus int
+
+const (
+	// HealthUnknown is the status before the first check
+	HealthUnknown HealthStatus = iota
+	// HealthOK is reported when the app answered in time with the expected key
+	HealthOK
+	// HealthSlow is reported when the app answered correctly but slower than HealthCheckOptions.MaxLatency
+	HealthSlow
+	// HealthLocked is reported when the device is locked
+	HealthLocked
+	// HealthKeyMismatch is reported when the device returned another key than the expected one
+	HealthKeyMismatch
+	// HealthUnreachable is reported when the device or app did not answer
+	HealthUnreachable
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthUnknown:
+		return "unknown"
+	case HealthOK:
+		return "ok"
+	case HealthSlow:
+		return "slow"
+	case HealthLocked:
+		return "locked"
+	case HealthKeyMismatch:
+		return "key mismatch"
+	case HealthUnreachable:
+		return "unreachable"
+	default:
+		return fmt.Sprintf("HealthStatus(%d)", int(s))
+	}
+}
+
+// HealthReport is the result of one health check
+type HealthReport struct {
+	Status HealthStatus
+	Time   time.Time
+	// Version is the app version, nil if the app did not answer
+	Version *VersionInfo
+	// Latency is the time taken by the version and public key requests
+	Latency time.Duration
+	// Err is the failure of an unhealthy check
+	Err error
+}
+
+// HealthCheckOptions configures a HealthChecker
+type HealthCheckOptions struct {
+	// Interval between checks, defaults to 10s
+	Interval time.Duration
+	// Timeout after which a check is reported unreachable, defaults to Interval
+	Timeout time.Duration
+	// MaxLatency, if set, reports slower checks as HealthSlow
+	MaxLatency time.Duration
+	// BIP32Path is the path of the consensus key, defaults to 44'/118'/0'/0'/0'
+	BIP32Path []uint32
+	// ExpectedPubKey is the consensus key the device must hold.
+	// When nil, the key returned by the first successful check is expected.
+	ExpectedPubKey []byte
+	// OnChange, if set, is called with the report of every check whose status differs from the previous one
+	OnChange func(report HealthReport)
+	// Changes, if set, receives the same reports as OnChange. Reports are dropped if the channel is full.
+	Changes chan<- HealthReport
+}
+
+// HealthChecker periodically checks that a validator device answers with the expected key,
+// so that a stuck, locked or replaced device is noticed before a block is missed
+type HealthChecker struct {
+	validator *LedgerTendermintValidator
+	opts      HealthCheckOptions
+
+	// checkMu serializes checks
+	checkMu sync.Mutex
+	// pending is closed when a check that timed out finally returns and releases the device
+	pending chan struct{}
+
+	mu       sync.Mutex
+	expected []byte
+	last     HealthReport
+}
+
+// NewHealthChecker returns a health checker for validator, started with Run
+func NewHealthChecker(validator *LedgerTendermintValidator, opts HealthCheckOptions) *HealthChecker {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.Interval
+	}
+	if opts.BIP32Path == nil {
+		opts.BIP32Path = []uint32{44, 118, 0, 0, 0}
+	}
+	return &HealthChecker{validator: validator, opts: opts, expected: opts.ExpectedPubKey}
+}
+
+// Run checks the device every interval until ctx is done
+func (h *HealthChecker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(h.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		h.Check()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the report of the last check
+func (h *HealthChecker) Last() HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// Check runs one health check and reports it if the status changed.
+// The device is not interrupted while it is used, e.g. to sign, the last report is returned instead.
+func (h *HealthChecker) Check() HealthReport {
+	h.checkMu.Lock()
+	defer h.checkMu.Unlock()
+
+	report, checked := h.check()
+	if !checked {
+		return h.Last()
+	}
+
+	h.mu.Lock()
+	changed := report.Status != h.last.Status
+	h.last = report
+	h.mu.Unlock()
+
+	if changed {
+		if h.opts.OnChange != nil {
+			h.opts.OnChange(report)
+		}
+		if h.opts.Changes != nil {
+			select {
+			case h.opts.Changes <- report:
+			default:
+			}
+		}
+	}
+	return report
+}
+
+// check returns false without a report when the device is in use
+func (h *HealthChecker) check() (HealthReport, bool) {
+	start := time.Now()
+
+	// a device stuck on a previous check is not asked again
+	if h.pending != nil {
+		select {
+		case <-h.pending:
+			h.pending = nil
+		default:
+			return HealthReport{Status: HealthUnreachable, Time: start, Err: ErrExchangeTimeout}, true
+		}
+	}
+
+	// the device stays locked until the check returns, even after a timeout, so that a stuck
+	// check never runs concurrently with a signature
+	if !h.validator.deviceMu.TryLock() {
+		return HealthReport{}, false
+	}
+
+	type result struct {
+		version *VersionInfo
+		pubkey  []byte
+		err     error
+	}
+	done := make(chan result, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer h.validator.deviceMu.Unlock()
+
+		version, err := h.validator.getVersion()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		pubkey, err := h.validator.getPublicKeyED25519(h.opts.BIP32Path)
+		done <- result{version: version, pubkey: pubkey, err: err}
+	}()
+
+	timer := time.NewTimer(h.opts.Timeout)
+	defer timer.Stop()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-timer.C:
+		h.pending = finished
+		return HealthReport{Status: HealthUnreachable, Time: start, Latency: time.Since(start), Err: ErrExchangeTimeout}, true
+	}
+
+	report := HealthReport{Time: start, Version: r.version, Latency: time.Since(start), Err: r.err}
+	switch {
+	case errors.Is(r.err, ErrDeviceLocked):
+		report.Status = HealthLocked
+	case r.err != nil:
+		report.Status = HealthUnreachable
+	case !h.expectKey(r.pubkey):
+		report.Status = HealthKeyMismatch
+		report.Err = fmt.Errorf("device returned public key %X", r.pubkey)
+	case h.opts.MaxLatency > 0 && report.Latency > h.opts.MaxLatency:
+		report.Status = HealthSlow
+	default:
+		report.Status = HealthOK
+	}
+	return report, true
+}
+
+// expectKey returns true if pubkey is the expected key, which is set on the first call if unknown
+func (h *HealthChecker) expectKey(pubkey []byte) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.expected == nil {
+		h.expected = pubkey
+	}
+	return bytes.Equal(pubkey, h.expected)
+}
@@ -0,0 +1,217 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"context"
+	"crypto/ed25519"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// healthTestApp is a validator app whose answers can be changed while it is checked
+type healthTestApp struct {
+	mu    sync.Mutex
+	key   ed25519.PrivateKey
+	err   error
+	delay time.Duration
+}
+
+func (a *healthTestApp) set(key ed25519.PrivateKey, err error, delay time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.key, a.err, a.delay = key, err, delay
+}
+
+func (a *healthTestApp) validator() *LedgerTendermintValidator {
+	return &LedgerTendermintValidator{api: newFakeDevice(func(apdu []byte) ([]byte, error) {
+		a.mu.Lock()
+		key, err, delay := a.key, a.err, a.delay
+		a.mu.Unlock()
+
+		time.Sleep(delay)
+		if err != nil {
+			return nil, err
+		}
+		switch apdu[1] {
+		case validatorINSGetVersion:
+			return []byte{0, 0, 9, 0}, nil
+		case validatorINSPublicKeyED25519:
+			return key.Public().(ed25519.PublicKey), nil
+		}
+		return nil, apduError(swINSNotSupported)
+	})}
+}
+
+func Test_HealthCheckStatuses(t *testing.T) {
+	app := &healthTestApp{key: testED25519Key()}
+	var changes []HealthStatus
+	checker := NewHealthChecker(app.validator(), HealthCheckOptions{
+		Timeout: time.Second,
+		OnChange: func(report HealthReport) {
+			changes = append(changes, report.Status)
+		},
+	})
+	assert.Equal(t, HealthUnknown, checker.Last().Status)
+
+	report := checker.Check()
+	assert.Equal(t, HealthOK, report.Status)
+	assert.Equal(t, &VersionInfo{0, 0, 9, 0}, report.Version)
+	assert.NoError(t, report.Err)
+	assert.Positive(t, report.Latency)
+
+	// unchanged statuses are not reported again
+	checker.Check()
+
+	app.set(testED25519Key(), apduError(swDeviceLocked), 0)
+	report = checker.Check()
+	assert.Equal(t, HealthLocked, report.Status)
+	assert.ErrorIs(t, report.Err, ErrDeviceLocked)
+	assert.Nil(t, report.Version)
+
+	app.set(testED25519Key(), errTestTransport, 0)
+	assert.Equal(t, HealthUnreachable, checker.Check().Status)
+
+	// the first key seen is the expected one
+	app.set(testConnKey("another seed"), nil, 0)
+	report = checker.Check()
+	assert.Equal(t, HealthKeyMismatch, report.Status)
+	assert.Error(t, report.Err)
+
+	app.set(testED25519Key(), nil, 0)
+	assert.Equal(t, HealthOK, checker.Check().Status)
+	assert.Equal(t, HealthOK, checker.Last().Status)
+
+	assert.Equal(t, []HealthStatus{HealthOK, HealthLocked, HealthUnreachable, HealthKeyMismatch, HealthOK}, changes)
+}
+
+func Test_HealthCheckExpectedKey(t *testing.T) {
+	app := &healthTestApp{key: testED25519Key()}
+	checker := NewHealthChecker(app.validator(), HealthCheckOptions{
+		ExpectedPubKey: testConnKey("another seed").Public().(ed25519.PublicKey),
+	})
+	assert.Equal(t, HealthKeyMismatch, checker.Check().Status)
+}
+
+func Test_HealthCheckLatency(t *testing.T) {
+	app := &healthTestApp{key: testED25519Key()}
+	checker := NewHealthChecker(app.validator(), HealthCheckOptions{
+		Timeout:    100 * time.Millisecond,
+		MaxLatency: 20 * time.Millisecond,
+	})
+
+	app.set(testED25519Key(), nil, 15*time.Millisecond)
+	report := checker.Check()
+	assert.Equal(t, HealthSlow, report.Status)
+	assert.GreaterOrEqual(t, report.Latency, 30*time.Millisecond)
+
+	// a stuck device is reported unreachable and not asked again until it answers
+	app.set(testED25519Key(), nil, 200*time.Millisecond)
+	report = checker.Check()
+	assert.Equal(t, HealthUnreachable, report.Status)
+	assert.ErrorIs(t, report.Err, ErrExchangeTimeout)
+
+	report = checker.Check()
+	assert.Equal(t, HealthUnreachable, report.Status)
+	assert.Zero(t, report.Latency)
+
+	app.set(testED25519Key(), nil, 0)
+	assert.Eventually(t, func() bool {
+		return checker.Check().Status == HealthOK
+	}, time.Second, 20*time.Millisecond)
+}
+
+func Test_HealthCheckRun(t *testing.T) {
+	app := &healthTestApp{key: testED25519Key()}
+	changes := make(chan HealthReport, 4)
+	checker := NewHealthChecker(app.validator(), HealthCheckOptions{
+		Interval: 10 * time.Millisecond,
+		Changes:  changes,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Run(ctx)
+	}()
+
+	assert.Equal(t, HealthOK, (<-changes).Status)
+	app.set(testED25519Key(), apduError(swDeviceLocked), 0)
+	assert.Equal(t, HealthLocked, (<-changes).Status)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func Test_HealthCheckDuringSign(t *testing.T) {
+	key := testED25519Key()
+	app := fakeSigningValidatorApp(key, nil)
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		time.Sleep(time.Millisecond)
+		return app.Exchange(apdu)
+	})
+	validator := &LedgerTendermintValidator{api: device}
+	checker := NewHealthChecker(validator, HealthCheckOptions{Timeout: time.Second})
+
+	done := make(chan struct{})
+	var checks int
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			message := make([]byte, 4*validatorMessageChunkSize)
+			message[0] = byte(i)
+			_, err := validator.SignED25519([]uint32{44, 118, 0, 0, 0}, message)
+			assert.NoError(t, err)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			if checker.Check().Status == HealthOK {
+				checks++
+			}
+		}
+	}
+	assert.Positive(t, checks)
+
+	// every signature is sent as one uninterrupted sequence of chunks
+	sent := device.Sent()
+	for i := 0; i < len(sent); i++ {
+		if sent[i][1] != validatorINSSignED25519 {
+			continue
+		}
+		count := int(sent[i][3])
+		require.Equal(t, byte(1), sent[i][2], "APDU %d", i)
+		require.LessOrEqual(t, i+count, len(sent))
+		for j := 1; j < count; j++ {
+			require.Equal(t, byte(validatorINSSignED25519), sent[i+j][1], "APDU %d", i+j)
+			require.Equal(t, byte(j+1), sent[i+j][2], "APDU %d", i+j)
+		}
+		i += count - 1
+	}
+}
+
+func Test_HealthStatusString(t *testing.T) {
+	assert.Equal(t, "key mismatch", HealthKeyMismatch.String())
+	assert.Equal(t, "HealthStatus(9)", HealthStatus(9).String())
+}
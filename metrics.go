@@ -0,0 +1,290 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+// Status classes used to count errors
+const (
+	StatusClassOK           = "ok"
+	StatusClassUserRejected = "user_rejected"
+	StatusClassBusy         = "busy"
+	StatusClassLocked       = "locked"
+	StatusClassDevice       = "device_error"
+	StatusClassTransport    = "transport_error"
+)
+
+var (
+	// DefaultLatencyBuckets are the upper bounds, in seconds, used for latency histograms
+	DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	// DefaultChunkBuckets are the upper bounds, in bytes, used for the chunk size histogram
+	DefaultChunkBuckets = []float64{8, 16, 32, 48, 64, 128, 255}
+)
+
+// ExchangeMetric describes a single APDU exchange
+type ExchangeMetric struct {
+	CLA        byte
+	INS        byte
+	Sent       int
+	Received   int
+	Duration   time.Duration
+	StatusWord uint16
+	Err        error
+}
+
+// SignMetric describes a complete signing operation
+type SignMetric struct {
+	// Operation is SignSECP256K1 or SignED25519
+	Operation string
+	// Transport is the rest of the operation: the other chunks and any request made along,
+	// such as fetching the public key to verify the signature
+	Transport time.Duration
+	// Confirmation is the time spent waiting for the answer to the last chunk,
+	// which includes the user confirmation on the device
+	Confirmation time.Duration
+	Err          error
+}
+
+// Metrics receives operational measurements from the app clients
+type Metrics interface {
+	ObserveExchange(metric ExchangeMetric)
+	ObserveSign(metric SignMetric)
+}
+
+// NopMetrics discards every measurement
+type NopMetrics struct{}
+
+func (NopMetrics) ObserveExchange(ExchangeMetric) {}
+
+func (NopMetrics) ObserveSign(SignMetric) {}
+
+// SetMetrics reports device operations to metrics. Pass nil to stop reporting.
+// It should be called before the app is used concurrently.
+func (ledger *LedgerCosmos) SetMetrics(metrics Metrics) {
+	device := instrument(ledger.api)
+	device.setMetrics(metrics)
+	ledger.api = device
+}
+
+// SetMetrics reports device operations to metrics. Pass nil to stop reporting.
+// It should be called before the app is used concurrently.
+func (ledger *LedgerTendermintValidator) SetMetrics(metrics Metrics) {
+	device := instrument(ledger.api)
+	device.setMetrics(metrics)
+	ledger.api = device
+}
+
+// observeSign reports a signing operation that started at start if device collects metrics.
+// confirmation is the duration of the last chunk as recorded by exchangeChunks, zero if it was
+// not sent. It is meant to be deferred, so the confirmation and error are read through pointers.
+func observeSign(device ledger_go.LedgerDevice, operation string, start time.Time, confirmation *time.Duration, err *error) {
+	d, ok := device.(*instrumentedDevice)
+	if !ok {
+		return
+	}
+
+	d.mu.RLock()
+	metrics := d.metrics
+	d.mu.RUnlock()
+	if metrics == nil {
+		return
+	}
+
+	total := time.Since(start)
+	metrics.ObserveSign(SignMetric{
+		Operation:    operation,
+		Transport:    total - *confirmation,
+		Confirmation: *confirmation,
+		Err:          *err,
+	})
+}
+
+// StatusClass groups the outcome of an exchange for error accounting
+func StatusClass(statusWord uint16, err error) string {
+	switch {
+	case err == nil:
+		return StatusClassOK
+	case statusWord == 0:
+		return StatusClassTransport
+	}
+
+	err = deviceError(err)
+	switch {
+	case errors.Is(err, ErrUserRejected):
+		return StatusClassUserRejected
+	case errors.Is(err, ErrDeviceBusy):
+		return StatusClassBusy
+	case errors.Is(err, ErrDeviceLocked):
+		return StatusClassLocked
+	default:
+		return StatusClassDevice
+	}
+}
+
+// instructionName returns a readable name for the instructions this library sends
+func instructionName(cla, ins byte) string {
+	switch {
+	case cla == userCLA && ins == userINSGetVersion:
+		return "user_get_version"
+	case cla == userCLA && ins == userINSSignSECP256K1:
+		return "user_sign_secp256k1"
+	case cla == userCLA && ins == userINSGetAddrSecp256k1:
+		return "user_get_addr_secp256k1"
+	case cla == validatorCLA && ins == validatorINSGetVersion:
+		return "validator_get_version"
+	case cla == validatorCLA && ins == validatorINSPublicKeyED25519:
+		return "validator_public_key_ed25519"
+	case cla == validatorCLA && ins == validatorINSSignED25519:
+		return "validator_sign_ed25519"
+	case cla == dashboardCLA && ins == dashboardINSGetAppName:
+		return "dashboard_get_app_name"
+	default:
+		return fmt.Sprintf("%02x_%02x", cla, ins)
+	}
+}
+
+// Histogram counts observations into buckets with the given upper bounds.
+// Counts has one more entry than Bounds for observations above the last bound.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int64   `json:"counts"`
+	Count  int64     `json:"count"`
+	Sum    float64   `json:"sum"`
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		Bounds: bounds,
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+func (h *Histogram) observe(value float64) {
+	h.Counts[sort.SearchFloat64s(h.Bounds, value)]++
+	h.Count++
+	h.Sum += value
+}
+
+func (h *Histogram) clone() Histogram {
+	return Histogram{
+		Bounds: h.Bounds,
+		Counts: append([]int64{}, h.Counts...),
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
+
+// MetricsSnapshot is a point in time copy of MemoryMetrics
+type MetricsSnapshot struct {
+	// Operations counts exchanges per instruction
+	Operations map[string]int64 `json:"operations"`
+	// Errors counts exchanges per status class
+	Errors map[string]int64 `json:"errors"`
+	// ChunkBytes is the distribution of data bytes sent per exchange
+	ChunkBytes Histogram `json:"chunk_bytes"`
+	// SignTransport is the distribution of transport time in seconds per sign operation
+	SignTransport map[string]Histogram `json:"sign_transport_seconds"`
+	// SignConfirmation is the distribution of confirmation wait in seconds per sign operation
+	SignConfirmation map[string]Histogram `json:"sign_confirmation_seconds"`
+}
+
+// MemoryMetrics keeps measurements in memory. It can be published with expvar.
+type MemoryMetrics struct {
+	mu               sync.Mutex
+	operations       map[string]int64
+	errors           map[string]int64
+	chunkBytes       *Histogram
+	signTransport    map[string]*Histogram
+	signConfirmation map[string]*Histogram
+}
+
+// NewMemoryMetrics creates an empty MemoryMetrics
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{
+		operations:       make(map[string]int64),
+		errors:           make(map[string]int64),
+		chunkBytes:       newHistogram(DefaultChunkBuckets),
+		signTransport:    make(map[string]*Histogram),
+		signConfirmation: make(map[string]*Histogram),
+	}
+}
+
+func (m *MemoryMetrics) ObserveExchange(metric ExchangeMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.operations[instructionName(metric.CLA, metric.INS)]++
+	if metric.Err != nil {
+		m.errors[StatusClass(metric.StatusWord, metric.Err)]++
+	}
+	m.chunkBytes.observe(float64(metric.Sent))
+}
+
+func (m *MemoryMetrics) ObserveSign(metric SignMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.signTransport[metric.Operation] == nil {
+		m.signTransport[metric.Operation] = newHistogram(DefaultLatencyBuckets)
+		m.signConfirmation[metric.Operation] = newHistogram(DefaultLatencyBuckets)
+	}
+	m.signTransport[metric.Operation].observe(metric.Transport.Seconds())
+	m.signConfirmation[metric.Operation].observe(metric.Confirmation.Seconds())
+}
+
+// Snapshot returns a copy of the current measurements
+func (m *MemoryMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Operations:       make(map[string]int64, len(m.operations)),
+		Errors:           make(map[string]int64, len(m.errors)),
+		ChunkBytes:       m.chunkBytes.clone(),
+		SignTransport:    make(map[string]Histogram, len(m.signTransport)),
+		SignConfirmation: make(map[string]Histogram, len(m.signConfirmation)),
+	}
+	for k, v := range m.operations {
+		snapshot.Operations[k] = v
+	}
+	for k, v := range m.errors {
+		snapshot.Errors[k] = v
+	}
+	for k, v := range m.signTransport {
+		snapshot.SignTransport[k] = v.clone()
+	}
+	for k, v := range m.signConfirmation {
+		snapshot.SignConfirmation[k] = v.clone()
+	}
+	return snapshot
+}
+
+// Publish exports the metrics as an expvar variable. Like expvar.Publish, it panics if name is already in use.
+func (m *MemoryMetrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return m.Snapshot()
+	}))
+}
@@ -0,0 +1,126 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MemoryMetricsUserSign(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		if apdu[2] == 2 {
+			time.Sleep(20 * time.Millisecond)
+			return []byte{0x30, 0x44}, nil
+		}
+		return nil, nil
+	})
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{Major: 2}}
+
+	metrics := NewMemoryMetrics()
+	userApp.SetMetrics(metrics)
+
+	_, err := userApp.SignSECP256K1([]uint32{44, 118, 0, 0, 0}, getDummyTx(), 0)
+	require.NoError(t, err)
+
+	device.handler = func([]byte) ([]byte, error) {
+		return nil, apduError(swCommandNotAllowed)
+	}
+	_, err = userApp.SignSECP256K1([]uint32{44, 118, 0, 0, 0}, getDummyTx(), 0)
+	require.Error(t, err)
+
+	chunks := int64(len(device.Sent()))
+	snapshot := metrics.Snapshot()
+
+	assert.Equal(t, chunks, snapshot.Operations["user_sign_secp256k1"])
+	assert.Equal(t, int64(1), snapshot.Errors[StatusClassUserRejected])
+	assert.Equal(t, chunks, snapshot.ChunkBytes.Count)
+
+	confirmation := snapshot.SignConfirmation["SignSECP256K1"]
+	assert.Equal(t, int64(2), confirmation.Count)
+	assert.GreaterOrEqual(t, confirmation.Sum, 0.02)
+	assert.Equal(t, int64(2), snapshot.SignTransport["SignSECP256K1"].Count)
+}
+
+// publishedMetrics makes expvar names unique across repeated test runs
+var publishedMetrics atomic.Int64
+
+func Test_MemoryMetricsValidatorAndExpvar(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		return nil, errors.New("hidapi: failed to read")
+	})
+	validatorApp := &LedgerTendermintValidator{api: device, version: VersionInfo{Minor: 9}}
+
+	metrics := NewMemoryMetrics()
+	validatorApp.SetMetrics(metrics)
+	name := fmt.Sprintf("%s_%d", t.Name(), publishedMetrics.Add(1))
+	metrics.Publish(name)
+
+	_, err := validatorApp.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	require.Error(t, err)
+
+	var exported MetricsSnapshot
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &exported))
+	assert.Equal(t, int64(1), exported.Operations["validator_sign_ed25519"])
+	assert.Equal(t, int64(1), exported.Errors[StatusClassTransport])
+	assert.Equal(t, int64(1), exported.SignTransport["SignED25519"].Count)
+}
+
+func Test_MemoryMetricsConfirmationIsLastChunk(t *testing.T) {
+	key := testED25519Key()
+	app := fakeSigningValidatorApp(key, nil)
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		switch {
+		case apdu[1] == validatorINSSignED25519 && apdu[2] == apdu[3]:
+			time.Sleep(10 * time.Millisecond)
+		case apdu[1] == validatorINSPublicKeyED25519:
+			time.Sleep(100 * time.Millisecond)
+		}
+		return app.Exchange(apdu)
+	})
+	validatorApp := &LedgerTendermintValidator{api: device, version: VersionInfo{Minor: 9}}
+
+	metrics := NewMemoryMetrics()
+	validatorApp.SetMetrics(metrics)
+
+	// the first signature also fetches the public key, which is not part of the confirmation
+	_, err := validatorApp.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	require.NoError(t, err)
+
+	snapshot := metrics.Snapshot()
+	confirmation := snapshot.SignConfirmation["SignED25519"]
+	require.Equal(t, int64(1), confirmation.Count)
+	assert.GreaterOrEqual(t, confirmation.Sum, 0.01)
+	assert.Less(t, confirmation.Sum, 0.1)
+	assert.GreaterOrEqual(t, snapshot.SignTransport["SignED25519"].Sum, 0.1)
+}
+
+func Test_StatusClass(t *testing.T) {
+	assert.Equal(t, StatusClassOK, StatusClass(swOK, nil))
+	assert.Equal(t, StatusClassTransport, StatusClass(0, errors.New("timeout")))
+	assert.Equal(t, StatusClassBusy, StatusClass(swBusy, apduError(swBusy)))
+	assert.Equal(t, StatusClassLocked, StatusClass(swDeviceLocked, apduError(swDeviceLocked)))
+	assert.Equal(t, StatusClassDevice, StatusClass(swWrongLength, apduError(swWrongLength)))
+}
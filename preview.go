@@ -0,0 +1,307 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cosmos/ledger-cosmos-go/textual"
+)
+
+// Page is one screen of a transaction as shown by the Cosmos app
+type Page struct {
+	Title string
+	Value string
+	// Expert pages are only shown when the device is in expert mode
+	Expert bool
+}
+
+// PreviewOptions configures how a payload is rendered by Preview
+type PreviewOptions struct {
+	// Model selects the page width, unknown models use the narrowest screen
+	Model DeviceModel
+	// Expert includes the pages only shown in expert mode
+	Expert bool
+	// CharsPerPage overrides the number of value characters per page of the model
+	CharsPerPage int
+}
+
+// CharsPerPage returns how many value characters the Cosmos app shows on one page
+func (m DeviceModel) CharsPerPage() int {
+	switch m {
+	case DeviceModelNanoX, DeviceModelNanoSPlus:
+		return 51
+	case DeviceModelStax, DeviceModelFlex:
+		return 180
+	default:
+		return 17
+	}
+}
+
+// Preview returns the pages the Cosmos app displays for payload, in order, so that a
+// transaction can be reviewed on the host before confirming it on the device.
+// p2 selects the sign mode like in SignSECP256K1: 0 for amino JSON and 1 for textual.
+// Values longer than a page are split and their titles numbered, e.g. "Memo [1/2]".
+func Preview(payload []byte, p2 byte, opts PreviewOptions) ([]Page, error) {
+	var pages []Page
+	var err error
+
+	switch p2 {
+	case 0:
+		pages, err = previewAminoJSON(payload, opts.Model)
+	case 1:
+		pages, err = previewTextual(payload)
+	default:
+		return nil, fmt.Errorf("unsupported sign mode P2=%d", p2)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	width := opts.CharsPerPage
+	if width <= 0 {
+		width = opts.Model.CharsPerPage()
+	}
+
+	var result []Page
+	for _, page := range pages {
+		if page.Expert && !opts.Expert {
+			continue
+		}
+		result = append(result, paginate(page, width)...)
+	}
+	return result, nil
+}
+
+// paginate splits the value of page in chunks of width characters
+func paginate(page Page, width int) []Page {
+	value := []rune(page.Value)
+	if len(value) <= width {
+		return []Page{page}
+	}
+
+	count := (len(value) + width - 1) / width
+	pages := make([]Page, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * width
+		if end > len(value) {
+			end = len(value)
+		}
+		pages = append(pages, Page{
+			Title:  fmt.Sprintf("%s [%d/%d]", page.Title, i+1, count),
+			Value:  string(value[i*width : end]),
+			Expert: page.Expert,
+		})
+	}
+	return pages
+}
+
+func previewTextual(payload []byte) ([]Page, error) {
+	screens, err := textual.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]Page, 0, len(screens))
+	for _, screen := range screens {
+		pages = append(pages, Page{
+			Title:  strings.Repeat("> ", screen.Indent) + screen.Title,
+			Value:  screen.Content,
+			Expert: screen.Expert,
+		})
+	}
+	return pages, nil
+}
+
+// aminoPreviewDoc holds the top-level fields of an amino sign doc as raw JSON
+type aminoPreviewDoc struct {
+	AccountNumber json.RawMessage   `json:"account_number"`
+	ChainID       string            `json:"chain_id"`
+	Fee           json.RawMessage   `json:"fee"`
+	Memo          string            `json:"memo"`
+	Msgs          []json.RawMessage `json:"msgs"`
+	Sequence      json.RawMessage   `json:"sequence"`
+	TimeoutHeight json.RawMessage   `json:"timeout_height"`
+}
+
+func previewAminoJSON(payload []byte, model DeviceModel) ([]Page, error) {
+	if err := ValidateAminoJSON(payload, model); err != nil {
+		return nil, err
+	}
+
+	var doc aminoPreviewDoc
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, err
+	}
+
+	pages := []Page{
+		{Title: "Chain ID", Value: doc.ChainID},
+		{Title: "Account", Value: jsonScalar(doc.AccountNumber), Expert: true},
+		{Title: "Sequence", Value: jsonScalar(doc.Sequence), Expert: true},
+	}
+	if doc.TimeoutHeight != nil {
+		pages = append(pages, Page{Title: "Timeout Height", Value: jsonScalar(doc.TimeoutHeight), Expert: true})
+	}
+
+	for i, raw := range doc.Msgs {
+		msgPages, err := previewAminoMsg(raw)
+		if err != nil {
+			return nil, fmt.Errorf("msg %d: %w", i, err)
+		}
+		pages = append(pages, msgPages...)
+	}
+
+	if doc.Memo != "" {
+		pages = append(pages, Page{Title: "Memo", Value: doc.Memo})
+	}
+
+	var fee struct {
+		Amount json.RawMessage `json:"amount"`
+		Gas    json.RawMessage `json:"gas"`
+	}
+	if err := json.Unmarshal(doc.Fee, &fee); err != nil {
+		return nil, fmt.Errorf("fee: %w", err)
+	}
+	amount, err := decodePreviewValue(fee.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("fee: %w", err)
+	}
+	feeValue, ok := formatCoins(amount)
+	if !ok {
+		return nil, errors.New("fee amount is not a list of coins")
+	}
+	pages = append(pages,
+		Page{Title: "Fee", Value: feeValue},
+		Page{Title: "Gas", Value: jsonScalar(fee.Gas), Expert: true},
+	)
+
+	return pages, nil
+}
+
+// previewAminoMsg renders the type of a message followed by its flattened value
+func previewAminoMsg(raw json.RawMessage) ([]Page, error) {
+	msg, err := decodePreviewValue(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	object, ok := msg.(map[string]any)
+	msgType, hasType := object["type"].(string)
+	if !ok || !hasType {
+		// messages that are not {"type":...,"value":...} are shown as they are
+		var pages []Page
+		flattenPreview("Msg", msg, &pages)
+		return pages, nil
+	}
+
+	pages := []Page{{Title: "Type", Value: msgType}}
+	flattenPreview("", object["value"], &pages)
+	return pages, nil
+}
+
+// flattenPreview appends one page per leaf of value, titled with the path of object keys.
+// Coins are rendered as "<amount> <denom>" on a single page.
+func flattenPreview(title string, value any, pages *[]Page) {
+	if coins, ok := formatCoins(value); ok && coins != "" {
+		*pages = append(*pages, Page{Title: title, Value: coins})
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if title != "" {
+				child = title + "/" + key
+			}
+			flattenPreview(child, v[key], pages)
+		}
+	case []any:
+		for _, item := range v {
+			flattenPreview(title, item, pages)
+		}
+	case nil:
+		*pages = append(*pages, Page{Title: title, Value: "null"})
+	default:
+		*pages = append(*pages, Page{Title: title, Value: fmt.Sprint(v)})
+	}
+}
+
+// formatCoins renders a coin or a list of coins, it reports false for anything else
+func formatCoins(value any) (string, bool) {
+	coin := func(v any) (string, bool) {
+		object, ok := v.(map[string]any)
+		if !ok || len(object) != 2 {
+			return "", false
+		}
+		denom, ok := object["denom"].(string)
+		if !ok {
+			return "", false
+		}
+		switch amount := object["amount"].(type) {
+		case string, json.Number:
+			return fmt.Sprintf("%v %s", amount, denom), true
+		default:
+			return "", false
+		}
+	}
+
+	list, isList := value.([]any)
+	if !isList {
+		return coin(value)
+	}
+
+	formatted := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := coin(item)
+		if !ok {
+			return "", false
+		}
+		formatted = append(formatted, s)
+	}
+	return strings.Join(formatted, ", "), true
+}
+
+func decodePreviewValue(raw json.RawMessage) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// jsonScalar renders a JSON string or number without quotes
+func jsonScalar(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
@@ -0,0 +1,128 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/ledger-cosmos-go/textual"
+)
+
+func testSendSignDoc(t *testing.T, memo string) []byte {
+	t.Helper()
+	bz, err := StdSignDoc{
+		AccountNumber: 7,
+		ChainID:       "cosmoshub-4",
+		Fee:           StdFee{Amount: []Coin{{Amount: "5000", Denom: "uatom"}}, Gas: 200000},
+		Memo:          memo,
+		Msgs: []json.RawMessage{json.RawMessage(`{"type":"cosmos-sdk/MsgSend","value":{` +
+			`"amount":[{"amount":"10","denom":"uatom"}],"from_address":"cosmos1from","to_address":"cosmos1to"}}`)},
+		Sequence: 3,
+	}.Marshal()
+	require.NoError(t, err)
+	return bz
+}
+
+func Test_PreviewAminoJSON(t *testing.T) {
+	pages, err := Preview(testSendSignDoc(t, "hi"), 0, PreviewOptions{Model: DeviceModelNanoX})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Page{
+		{Title: "Chain ID", Value: "cosmoshub-4"},
+		{Title: "Type", Value: "cosmos-sdk/MsgSend"},
+		{Title: "amount", Value: "10 uatom"},
+		{Title: "from_address", Value: "cosmos1from"},
+		{Title: "to_address", Value: "cosmos1to"},
+		{Title: "Memo", Value: "hi"},
+		{Title: "Fee", Value: "5000 uatom"},
+	}, pages)
+}
+
+func Test_PreviewAminoJSONExpert(t *testing.T) {
+	pages, err := Preview(getDummyTx(), 0, PreviewOptions{Expert: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Page{
+		{Title: "Chain ID", Value: "some_chain"},
+		{Title: "Account", Value: "1", Expert: true},
+		{Title: "Sequence", Value: "3", Expert: true},
+		{Title: "Msg", Value: "SOMETHING"},
+		{Title: "Memo", Value: "MEMO"},
+		{Title: "Fee", Value: "10 DEN"},
+		{Title: "Gas", Value: "5", Expert: true},
+	}, pages)
+}
+
+func Test_PreviewPagination(t *testing.T) {
+	memo := "0123456789012345678901234567890123456789"
+	bz := testSendSignDoc(t, memo)
+
+	pages, err := Preview(bz, 0, PreviewOptions{Model: DeviceModelNanoS})
+	require.NoError(t, err)
+	assert.Contains(t, pages, Page{Title: "Memo [1/3]", Value: memo[:17]})
+	assert.Contains(t, pages, Page{Title: "Memo [2/3]", Value: memo[17:34]})
+	assert.Contains(t, pages, Page{Title: "Memo [3/3]", Value: memo[34:]})
+
+	pages, err = Preview(bz, 0, PreviewOptions{CharsPerPage: 20})
+	require.NoError(t, err)
+	assert.Contains(t, pages, Page{Title: "Memo [2/2]", Value: memo[20:]})
+
+	pages, err = Preview(bz, 0, PreviewOptions{Model: DeviceModelStax})
+	require.NoError(t, err)
+	assert.Contains(t, pages, Page{Title: "Memo", Value: memo})
+}
+
+func Test_PreviewTextual(t *testing.T) {
+	bz, err := textual.Encode([]textual.Screen{
+		{Title: "Chain id", Content: "cosmoshub-4"},
+		{Title: "Message", Content: "MsgSend object"},
+		{Title: "Amount", Content: "10 ATOM", Indent: 1},
+		{Title: "Hash of raw bytes", Content: "9c1f", Expert: true},
+		{Title: "Memo", Content: "désolé, très long mémo"},
+	})
+	require.NoError(t, err)
+
+	pages, err := Preview(bz, 1, PreviewOptions{Model: DeviceModelNanoS})
+	require.NoError(t, err)
+	assert.Equal(t, []Page{
+		{Title: "Chain id", Value: "cosmoshub-4"},
+		{Title: "Message", Value: "MsgSend object"},
+		{Title: "> Amount", Value: "10 ATOM"},
+		{Title: "Memo [1/2]", Value: "désolé, très long"},
+		{Title: "Memo [2/2]", Value: " mémo"},
+	}, pages)
+
+	pages, err = Preview(bz, 1, PreviewOptions{Model: DeviceModelNanoX, Expert: true})
+	require.NoError(t, err)
+	assert.Contains(t, pages, Page{Title: "Hash of raw bytes", Value: "9c1f", Expert: true})
+}
+
+func Test_PreviewErrors(t *testing.T) {
+	_, err := Preview(getDummyTx(), 2, PreviewOptions{})
+	assert.Error(t, err)
+
+	_, err = Preview(append([]byte{'A'}, getDummyTx()...), 0, PreviewOptions{})
+	var validationErr *AminoValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = Preview(getDummyTx(), 1, PreviewOptions{})
+	assert.Error(t, err)
+}
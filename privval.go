@@ -0,0 +1,477 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// tendermint.privval.Message fields of CometBFT v0.38, the version used by Cosmos SDK v0.50 chains,
+// https://github.com/cometbft/cometbft/blob/v0.38.x/proto/tendermint/privval/types.proto
+const (
+	privvalPubKeyRequest          = 1
+	privvalPubKeyResponse         = 2
+	privvalSignVoteRequest        = 3
+	privvalSignedVoteResponse     = 4
+	privvalSignProposalRequest    = 5
+	privvalSignedProposalResponse = 6
+	privvalPingRequest            = 7
+	privvalPingResponse           = 8
+
+	// privvalMaxMessageSize is the largest message CometBFT sends to a remote signer
+	privvalMaxMessageSize = 10 * 1024
+)
+
+// ValidatorSigner is the validator app API used by PrivvalServer
+type ValidatorSigner interface {
+	GetPublicKeyED25519(bip32Path []uint32) ([]byte, error)
+	SignED25519(bip32Path []uint32, message []byte) ([]byte, error)
+}
+
+var _ ValidatorSigner = (*LedgerTendermintValidator)(nil)
+
+// PrivvalOptions configures a PrivvalServer
+type PrivvalOptions struct {
+	// BIP32Path is the path of the consensus key, defaults to 44'/118'/0'/0'/0'
+	BIP32Path []uint32
+	// ConnKey authenticates the signer in the SecretConnection, it is required for tcp:// addresses.
+	// It is not the consensus key, which never leaves the device.
+	ConnKey ed25519.PrivateKey
+	// RetryInterval is the delay between connection attempts, defaults to 1s
+	RetryInterval time.Duration
+	// Logger receives connection and request errors, nothing is logged when nil
+	Logger *slog.Logger
+}
+
+// PrivvalServer serves CometBFT's privval remote signer protocol with a validator device.
+// CometBFT listens on priv_validator_laddr and the signer connects to it, then answers the
+// public key, vote, proposal and ping requests sent by the node.
+type PrivvalServer struct {
+	signer ValidatorSigner
+	opts   PrivvalOptions
+}
+
+// NewPrivvalServer returns a remote signer backed by signer, usually a *LedgerTendermintValidator
+func NewPrivvalServer(signer ValidatorSigner, opts PrivvalOptions) *PrivvalServer {
+	if opts.BIP32Path == nil {
+		opts.BIP32Path = []uint32{44, 118, 0, 0, 0}
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+	return &PrivvalServer{signer: signer, opts: opts}
+}
+
+// DialAndServe connects to the node at addr, tcp://host:port or unix:///path, and serves its
+// requests. The connection is reestablished when it drops, until ctx is done.
+func (s *PrivvalServer) DialAndServe(ctx context.Context, addr string) error {
+	network, address, err := parsePrivvalAddr(addr)
+	if err != nil {
+		return err
+	}
+	if network == "tcp" && s.opts.ConnKey == nil {
+		return errors.New("a connection key is required for tcp:// addresses")
+	}
+
+	for {
+		err := s.dialAndServeOnce(ctx, network, address)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.log("privval connection closed", "addr", addr, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.opts.RetryInterval):
+		}
+	}
+}
+
+func (s *PrivvalServer) dialAndServeOnce(ctx context.Context, network, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return err
+	}
+
+	if network == "tcp" {
+		secret, err := newSecretConnection(conn, s.opts.ConnKey)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		conn = secret
+	}
+	return s.ServeConn(ctx, conn)
+}
+
+// ServeConn answers the requests received on conn until it fails or ctx is done, then closes it.
+// conn must already be secured, DialAndServe takes care of it for TCP.
+func (s *PrivvalServer) ServeConn(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	reader := bufio.NewReader(conn)
+	for {
+		request, err := readDelimited(reader, privvalMaxMessageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		response, err := s.handle(request)
+		if err != nil {
+			return err
+		}
+		if err := writeDelimited(conn, response); err != nil {
+			return err
+		}
+	}
+}
+
+// handle decodes a privval.Message and returns the encoded response
+func (s *PrivvalServer) handle(request []byte) ([]byte, error) {
+	num, body, err := decodePrivvalMessage(request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch num {
+	case privvalPubKeyRequest:
+		return s.handlePubKey()
+	case privvalSignVoteRequest:
+		return s.handleSignVote(body)
+	case privvalSignProposalRequest:
+		return s.handleSignProposal(body)
+	case privvalPingRequest:
+		return encodePrivvalMessage(privvalPingResponse, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported privval request %d", num)
+	}
+}
+
+func (s *PrivvalServer) handlePubKey() ([]byte, error) {
+	var response []byte
+	pubkey, err := s.signer.GetPublicKeyED25519(s.opts.BIP32Path)
+	if err == nil {
+		response = appendMessageField(response, 1, encodeCryptoPublicKey(pubkey))
+	} else {
+		s.log("privval public key request failed", "err", err)
+		// pub_key is not nullable, CometBFT always sends it, empty along an error
+		response = appendMessageField(response, 1, nil)
+		response = appendMessageField(response, 2, encodeRemoteSignerError(err))
+	}
+	return encodePrivvalMessage(privvalPubKeyResponse, response), nil
+}
+
+func (s *PrivvalServer) handleSignVote(body []byte) ([]byte, error) {
+	var voteBytes []byte
+	var chainID string
+	var skipExtension bool
+	err := consumeFields(body, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			voteBytes = value
+		case 2:
+			chainID = string(value)
+		case 3:
+			skipExtension = varint != 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	vote, err := decodeProtoVote(voteBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.signVote(chainID, &vote, skipExtension)
+
+	var response []byte
+	response = appendMessageField(response, 1, vote.encode())
+	if err != nil {
+		s.log("privval vote signing failed", "height", vote.Height, "round", vote.Round, "type", vote.Type, "err", err)
+		response = appendMessageField(response, 2, encodeRemoteSignerError(err))
+	}
+	return encodePrivvalMessage(privvalSignedVoteResponse, response), nil
+}
+
+// signVote signs the vote and, for non nil precommits, its extension like CometBFT's FilePV
+func (s *PrivvalServer) signVote(chainID string, vote *protoVote, skipExtension bool) error {
+	signature, err := s.signer.SignED25519(s.opts.BIP32Path, vote.SignBytes(chainID))
+	if err != nil {
+		return err
+	}
+	vote.Signature = signature
+
+	if vote.Type == PrecommitType && !vote.BlockID.isZero() && !skipExtension {
+		extension := VoteExtension{Extension: vote.Extension, Height: vote.Height, Round: vote.Round}
+		signature, err := s.signer.SignED25519(s.opts.BIP32Path, extension.SignBytes(chainID))
+		if err != nil {
+			return err
+		}
+		vote.ExtensionSignature = signature
+	}
+	return nil
+}
+
+func (s *PrivvalServer) handleSignProposal(body []byte) ([]byte, error) {
+	var proposalBytes []byte
+	var chainID string
+	err := consumeFields(body, func(num protowire.Number, value []byte, _ uint64) error {
+		switch num {
+		case 1:
+			proposalBytes = value
+		case 2:
+			chainID = string(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := decodeProtoProposal(proposalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.signer.SignED25519(s.opts.BIP32Path, proposal.SignBytes(chainID))
+
+	if err == nil {
+		proposal.Signature = signature
+	}
+
+	var response []byte
+	response = appendMessageField(response, 1, proposal.encode())
+	if err != nil {
+		s.log("privval proposal signing failed", "height", proposal.Height, "round", proposal.Round, "err", err)
+		response = appendMessageField(response, 2, encodeRemoteSignerError(err))
+	}
+	return encodePrivvalMessage(privvalSignedProposalResponse, response), nil
+}
+
+func (s *PrivvalServer) log(msg string, args ...any) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Error(msg, args...)
+	}
+}
+
+func parsePrivvalAddr(addr string) (network, address string, err error) {
+	switch {
+	case strings.HasPrefix(addr, "tcp://"):
+		return "tcp", strings.TrimPrefix(addr, "tcp://"), nil
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://"), nil
+	default:
+		return "", "", fmt.Errorf("unsupported privval address %q, expected tcp:// or unix://", addr)
+	}
+}
+
+// protoVote is a tendermint.types.Vote as exchanged with the node
+type protoVote struct {
+	Vote
+	ValidatorAddress   []byte
+	ValidatorIndex     int32
+	Signature          []byte
+	Extension          []byte
+	ExtensionSignature []byte
+}
+
+func (v protoVote) encode() []byte {
+	var b []byte
+	b = appendVarintField(b, 1, uint64(v.Type))
+	b = appendVarintField(b, 2, uint64(v.Height))
+	b = appendVarintField(b, 3, uint64(int64(v.Round)))
+	b = appendMessageField(b, 4, encodeBlockID(v.BlockID))
+	b = appendMessageField(b, 5, encodeTimestamp(v.Timestamp))
+	b = appendBytesField(b, 6, v.ValidatorAddress)
+	b = appendVarintField(b, 7, uint64(int64(v.ValidatorIndex)))
+	b = appendBytesField(b, 8, v.Signature)
+	b = appendBytesField(b, 9, v.Extension)
+	return appendBytesField(b, 10, v.ExtensionSignature)
+}
+
+func decodeProtoVote(b []byte) (protoVote, error) {
+	var v protoVote
+	err := consumeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			v.Type = SignedMsgType(varint)
+		case 2:
+			v.Height = int64(varint)
+		case 3:
+			v.Round = int32(varint)
+		case 4:
+			id, err := decodeBlockID(value)
+			v.BlockID = id
+			return err
+		case 5:
+			t, err := decodeTimestamp(value)
+			v.Timestamp = t
+			return err
+		case 6:
+			v.ValidatorAddress = value
+		case 7:
+			v.ValidatorIndex = int32(varint)
+		case 8:
+			v.Signature = value
+		case 9:
+			v.Extension = value
+		case 10:
+			v.ExtensionSignature = value
+		}
+		return nil
+	})
+	return v, err
+}
+
+// protoProposal is a tendermint.types.Proposal as exchanged with the node
+type protoProposal struct {
+	Proposal
+	Signature []byte
+}
+
+func (p protoProposal) encode() []byte {
+	var b []byte
+	b = appendVarintField(b, 1, uint64(ProposalType))
+	b = appendVarintField(b, 2, uint64(p.Height))
+	b = appendVarintField(b, 3, uint64(int64(p.Round)))
+	b = appendVarintField(b, 4, uint64(int64(p.POLRound)))
+	b = appendMessageField(b, 5, encodeBlockID(p.BlockID))
+	b = appendMessageField(b, 6, encodeTimestamp(p.Timestamp))
+	return appendBytesField(b, 7, p.Signature)
+}
+
+func decodeProtoProposal(b []byte) (protoProposal, error) {
+	var p protoProposal
+	err := consumeFields(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 2:
+			p.Height = int64(varint)
+		case 3:
+			p.Round = int32(varint)
+		case 4:
+			p.POLRound = int32(varint)
+		case 5:
+			id, err := decodeBlockID(value)
+			p.BlockID = id
+			return err
+		case 6:
+			t, err := decodeTimestamp(value)
+			p.Timestamp = t
+			return err
+		case 7:
+			p.Signature = value
+		}
+		return nil
+	})
+	return p, err
+}
+
+func encodeBlockID(id BlockID) []byte {
+	var header []byte
+	header = appendVarintField(header, 1, uint64(id.PartSetHeader.Total))
+	header = appendBytesField(header, 2, id.PartSetHeader.Hash)
+
+	var b []byte
+	b = appendBytesField(b, 1, id.Hash)
+	return appendMessageField(b, 2, header)
+}
+
+func decodeBlockID(b []byte) (BlockID, error) {
+	var id BlockID
+	err := consumeFields(b, func(num protowire.Number, value []byte, _ uint64) error {
+		switch num {
+		case 1:
+			id.Hash = value
+		case 2:
+			return consumeFields(value, func(num protowire.Number, value []byte, varint uint64) error {
+				switch num {
+				case 1:
+					id.PartSetHeader.Total = uint32(varint)
+				case 2:
+					id.PartSetHeader.Hash = value
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return id, err
+}
+
+func decodeTimestamp(b []byte) (time.Time, error) {
+	var seconds int64
+	var nanos int32
+	err := consumeFields(b, func(num protowire.Number, _ []byte, varint uint64) error {
+		switch num {
+		case 1:
+			seconds = int64(varint)
+		case 2:
+			nanos = int32(varint)
+		}
+		return nil
+	})
+	return time.Unix(seconds, int64(nanos)).UTC(), err
+}
+
+// encodeRemoteSignerError encodes a privval.RemoteSignerError{code = 1, description = 2}
+func encodeRemoteSignerError(err error) []byte {
+	return appendStringField(nil, 2, err.Error())
+}
+
+func encodePrivvalMessage(num protowire.Number, body []byte) []byte {
+	return appendMessageField(nil, num, body)
+}
+
+// decodePrivvalMessage returns the field number and content of the oneof of a privval.Message
+func decodePrivvalMessage(msg []byte) (protowire.Number, []byte, error) {
+	num, typ, n := protowire.ConsumeTag(msg)
+	if n < 0 {
+		return 0, nil, protowire.ParseError(n)
+	}
+	if typ != protowire.BytesType {
+		return 0, nil, fmt.Errorf("unexpected privval message field %d", num)
+	}
+	body, m := protowire.ConsumeBytes(msg[n:])
+	if m < 0 {
+		return 0, nil, protowire.ParseError(m)
+	}
+	if n+m != len(msg) {
+		return 0, nil, errors.New("unexpected data after the privval message")
+	}
+	return num, body, nil
+}
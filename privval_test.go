@@ -0,0 +1,378 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// privvalNode plays the CometBFT side of the remote signer protocol
+type privvalNode struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (n *privvalNode) request(num protowire.Number, body []byte) (protowire.Number, []byte) {
+	require.NoError(n.t, n.conn.SetDeadline(time.Now().Add(5*time.Second)))
+	require.NoError(n.t, writeDelimited(n.conn, encodePrivvalMessage(num, body)))
+
+	response, err := readDelimited(n.reader, privvalMaxMessageSize)
+	require.NoError(n.t, err)
+	responseNum, responseBody, err := decodePrivvalMessage(response)
+	require.NoError(n.t, err)
+	return responseNum, responseBody
+}
+
+func (n *privvalNode) signVote(chainID string, vote protoVote, skipExtension bool) (protoVote, string) {
+	var request []byte
+	request = appendMessageField(request, 1, vote.encode())
+	request = appendStringField(request, 2, chainID)
+	if skipExtension {
+		request = appendVarintField(request, 3, 1)
+	}
+
+	num, body := n.request(privvalSignVoteRequest, request)
+	require.Equal(n.t, protowire.Number(privvalSignedVoteResponse), num)
+
+	var signed protoVote
+	var description string
+	require.NoError(n.t, consumeFields(body, func(num protowire.Number, value []byte, _ uint64) error {
+		var err error
+		switch num {
+		case 1:
+			signed, err = decodeProtoVote(value)
+		case 2:
+			description = remoteSignerErrorDescription(n.t, value)
+		}
+		return err
+	}))
+	return signed, description
+}
+
+func remoteSignerErrorDescription(t *testing.T, msg []byte) string {
+	var description string
+	require.NoError(t, consumeFields(msg, func(num protowire.Number, value []byte, _ uint64) error {
+		if num == 2 {
+			description = string(value)
+		}
+		return nil
+	}))
+	return description
+}
+
+// startPrivval runs a server for device against a local TCP listener and returns the accepted connection
+func startPrivval(t *testing.T, device *fakeDevice) (*privvalNode, net.Listener) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	server := NewPrivvalServer(&LedgerTendermintValidator{api: device}, PrivvalOptions{
+		ConnKey:       testConnKey("signer"),
+		RetryInterval: 10 * time.Millisecond,
+	})
+	go func() {
+		_ = server.DialAndServe(ctx, "tcp://"+listener.Addr().String())
+	}()
+
+	return acceptPrivval(t, listener), listener
+}
+
+func acceptPrivval(t *testing.T, listener net.Listener) *privvalNode {
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	secret, err := newSecretConnection(conn, testConnKey("node"))
+	require.NoError(t, err)
+	assert.Equal(t, testConnKey("signer").Public(), secret.RemotePubKey())
+	return &privvalNode{t: t, conn: secret, reader: bufio.NewReader(secret)}
+}
+
+func testProtoVote(voteType SignedMsgType, blockID BlockID) protoVote {
+	return protoVote{
+		Vote: Vote{
+			Type:      voteType,
+			Height:    12345,
+			Round:     2,
+			BlockID:   blockID,
+			Timestamp: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC),
+		},
+		ValidatorAddress: make([]byte, 20),
+		ValidatorIndex:   3,
+		Extension:        []byte("extension"),
+	}
+}
+
+var testBlockID = BlockID{
+	Hash:          make([]byte, 32),
+	PartSetHeader: PartSetHeader{Total: 1, Hash: make([]byte, 32)},
+}
+
+func Test_PrivvalPubKey(t *testing.T) {
+	key := testED25519Key()
+	node, _ := startPrivval(t, fakeSigningValidatorApp(key, nil))
+
+	num, body := node.request(privvalPubKeyRequest, appendStringField(nil, 1, "test-chain"))
+	require.Equal(t, protowire.Number(privvalPubKeyResponse), num)
+
+	var pubkey []byte
+	require.NoError(t, consumeFields(body, func(num protowire.Number, value []byte, _ uint64) error {
+		if num == 1 {
+			pubkey = value
+		}
+		return nil
+	}))
+	decoded, err := decodeCryptoPublicKey(pubkey)
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), decoded)
+}
+
+func Test_PrivvalPing(t *testing.T) {
+	node, _ := startPrivval(t, fakeSigningValidatorApp(testED25519Key(), nil))
+
+	num, body := node.request(privvalPingRequest, nil)
+	assert.Equal(t, protowire.Number(privvalPingResponse), num)
+	assert.Empty(t, body)
+}
+
+func Test_PrivvalSignVote(t *testing.T) {
+	key := testED25519Key()
+	pub := key.Public().(ed25519.PublicKey)
+	node, _ := startPrivval(t, fakeSigningValidatorApp(key, nil))
+
+	precommit := testProtoVote(PrecommitType, testBlockID)
+	signed, description := node.signVote("test-chain", precommit, false)
+	require.Empty(t, description)
+	assert.Equal(t, precommit.Vote, signed.Vote)
+	assert.Equal(t, precommit.ValidatorAddress, signed.ValidatorAddress)
+	assert.Equal(t, precommit.ValidatorIndex, signed.ValidatorIndex)
+	assert.True(t, ed25519.Verify(pub, precommit.SignBytes("test-chain"), signed.Signature))
+
+	extension := VoteExtension{Extension: precommit.Extension, Height: precommit.Height, Round: precommit.Round}
+	assert.True(t, ed25519.Verify(pub, extension.SignBytes("test-chain"), signed.ExtensionSignature))
+
+	// the extension is only signed for precommits of a block, unless the node asks to skip it
+	signed, description = node.signVote("test-chain", precommit, true)
+	require.Empty(t, description)
+	assert.NotEmpty(t, signed.Signature)
+	assert.Empty(t, signed.ExtensionSignature)
+
+	nilPrecommit := testProtoVote(PrecommitType, BlockID{})
+	signed, description = node.signVote("test-chain", nilPrecommit, false)
+	require.Empty(t, description)
+	assert.True(t, ed25519.Verify(pub, nilPrecommit.SignBytes("test-chain"), signed.Signature))
+	assert.Empty(t, signed.ExtensionSignature)
+
+	prevote := testProtoVote(PrevoteType, testBlockID)
+	signed, description = node.signVote("test-chain", prevote, false)
+	require.Empty(t, description)
+	assert.True(t, ed25519.Verify(pub, prevote.SignBytes("test-chain"), signed.Signature))
+	assert.Empty(t, signed.ExtensionSignature)
+}
+
+func Test_PrivvalSignProposal(t *testing.T) {
+	key := testED25519Key()
+	node, _ := startPrivval(t, fakeSigningValidatorApp(key, nil))
+
+	proposal := protoProposal{Proposal: Proposal{
+		Height:    12345,
+		Round:     1,
+		POLRound:  -1,
+		BlockID:   testBlockID,
+		Timestamp: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}}
+
+	var request []byte
+	request = appendMessageField(request, 1, proposal.encode())
+	request = appendStringField(request, 2, "test-chain")
+	num, body := node.request(privvalSignProposalRequest, request)
+	require.Equal(t, protowire.Number(privvalSignedProposalResponse), num)
+
+	var signed protoProposal
+	require.NoError(t, consumeFields(body, func(num protowire.Number, value []byte, _ uint64) error {
+		var err error
+		if num == 1 {
+			signed, err = decodeProtoProposal(value)
+		}
+		return err
+	}))
+	assert.Equal(t, proposal.Proposal, signed.Proposal)
+	assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), proposal.SignBytes("test-chain"), signed.Signature))
+}
+
+func Test_PrivvalSignError(t *testing.T) {
+	node, _ := startPrivval(t, fakeSigningValidatorApp(testED25519Key(), func(signature []byte) []byte {
+		signature[0] ^= 0xff
+		return signature
+	}))
+
+	signed, description := node.signVote("test-chain", testProtoVote(PrevoteType, testBlockID), false)
+	assert.Equal(t, ErrSignatureMismatch.Error(), description)
+	assert.Empty(t, signed.Signature)
+	assert.Equal(t, int64(12345), signed.Height)
+}
+
+func Test_PrivvalReconnect(t *testing.T) {
+	node, listener := startPrivval(t, fakeSigningValidatorApp(testED25519Key(), nil))
+	node.request(privvalPingRequest, nil)
+	node.conn.Close()
+
+	node = acceptPrivval(t, listener)
+	num, _ := node.request(privvalPingRequest, nil)
+	assert.Equal(t, protowire.Number(privvalPingResponse), num)
+}
+
+func Test_PrivvalUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "privval.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	server := NewPrivvalServer(&LedgerTendermintValidator{api: fakeSigningValidatorApp(testED25519Key(), nil)}, PrivvalOptions{})
+	go func() {
+		done <- server.DialAndServe(ctx, "unix://"+socket)
+	}()
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	node := &privvalNode{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	num, _ := node.request(privvalPingRequest, nil)
+	assert.Equal(t, protowire.Number(privvalPingResponse), num)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func Test_PrivvalAddress(t *testing.T) {
+	server := NewPrivvalServer(&LedgerTendermintValidator{}, PrivvalOptions{})
+
+	err := server.DialAndServe(context.Background(), "127.0.0.1:26659")
+	assert.ErrorContains(t, err, "unsupported privval address")
+
+	err = server.DialAndServe(context.Background(), "tcp://127.0.0.1:26659")
+	assert.ErrorContains(t, err, "connection key is required")
+}
+
+func Test_ProtoVoteRoundTrip(t *testing.T) {
+	vote := testProtoVote(PrecommitType, testBlockID)
+	vote.Round = -1
+	vote.Signature = []byte{1, 2, 3}
+	vote.ExtensionSignature = []byte{4, 5, 6}
+
+	decoded, err := decodeProtoVote(vote.encode())
+	require.NoError(t, err)
+	assert.Equal(t, vote, decoded)
+
+	_, err = decodeProtoVote([]byte{0x22, 0x05})
+	assert.Error(t, err)
+}
+
+// Test_PrivvalCometBFTVectors checks the encoding against the vectors of CometBFT v0.38 privval/msgs_test.go
+func Test_PrivvalCometBFTVectors(t *testing.T) {
+	mustDecodeHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+
+	num, body, err := decodePrivvalMessage(mustDecodeHex("3a00"))
+	require.NoError(t, err)
+	assert.Equal(t, protowire.Number(privvalPingRequest), num)
+	assert.Empty(t, body)
+	assert.Equal(t, "4200", hex.EncodeToString(encodePrivvalMessage(privvalPingResponse, nil)))
+	assert.Equal(t, "0a00", hex.EncodeToString(encodePrivvalMessage(privvalPubKeyRequest, nil)))
+
+	const voteHex = "0a7f080210031802224a0a208b01023386c371778ecb6368573e539afc3cc860ec3a2f614e54fe5652f4fc80" +
+		"122608c0843d122072db3d959635dff1bb567bedaa70573392c5159666a3f8caf11e413aac52207a2a0608f49a8ded05" +
+		"32146af1f4111082efb388211bc72c55bcd61e9ac3d538d5bb034a09657874656e73696f6e"
+
+	num, body, err = decodePrivvalMessage(mustDecodeHex("1a8101" + voteHex))
+	require.NoError(t, err)
+	require.Equal(t, protowire.Number(privvalSignVoteRequest), num)
+
+	var voteBytes []byte
+	require.NoError(t, consumeFields(body, func(num protowire.Number, value []byte, _ uint64) error {
+		if num == 1 {
+			voteBytes = value
+		}
+		return nil
+	}))
+	vote, err := decodeProtoVote(voteBytes)
+	require.NoError(t, err)
+
+	blockHash := sha256.Sum256([]byte("blockID_hash"))
+	partsHash := sha256.Sum256([]byte("blockID_part_set_header_hash"))
+	address := sha256.Sum256([]byte("validator_address"))
+	assert.Equal(t, protoVote{
+		Vote: Vote{
+			Type:   PrecommitType,
+			Height: 3,
+			Round:  2,
+			BlockID: BlockID{
+				Hash:          blockHash[:],
+				PartSetHeader: PartSetHeader{Total: 1000000, Hash: partsHash[:]},
+			},
+			Timestamp: time.Date(2019, 10, 13, 16, 14, 44, 0, time.UTC),
+		},
+		ValidatorAddress: address[:20],
+		ValidatorIndex:   56789,
+		Extension:        []byte("extension"),
+	}, vote)
+
+	response := encodePrivvalMessage(privvalSignedVoteResponse, appendMessageField(nil, 1, vote.encode()))
+	assert.Equal(t, "228101"+voteHex, hex.EncodeToString(response))
+}
+
+func Test_PrivvalPubKeyResponseVectors(t *testing.T) {
+	// the key of CometBFT's vectors, ed25519.GenPrivKeyFromSecret([]byte("it's a secret"))
+	seed := sha256.Sum256([]byte("it's a secret"))
+	key := ed25519.NewKeyFromSeed(seed[:])
+	request := encodePrivvalMessage(privvalPubKeyRequest, nil)
+
+	server := NewPrivvalServer(&LedgerTendermintValidator{api: fakeSigningValidatorApp(key, nil)}, PrivvalOptions{})
+	response, err := server.handle(request)
+	require.NoError(t, err)
+	assert.Equal(t, "12240a220a20556a436f1218d30942efe798420f51dc9b6a311b929c578257457d05c5fcf230", hex.EncodeToString(response))
+
+	// an empty pub_key and the error description, the code is left unset
+	failing := newTestSigner(key)
+	failing.fail(errors.New("it's a error"), 0)
+	response, err = NewPrivvalServer(failing, PrivvalOptions{}).handle(request)
+	require.NoError(t, err)
+	assert.Equal(t, "12120a00120e120c697427732061206572726f72", hex.EncodeToString(response))
+}
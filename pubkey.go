@@ -0,0 +1,253 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	Secp256k1PubKeyTypeURL = "/cosmos.crypto.secp256k1.PubKey"
+	Ed25519PubKeyTypeURL   = "/cosmos.crypto.ed25519.PubKey"
+
+	secp256k1PubKeyAminoName = "tendermint/PubKeySecp256k1"
+	ed25519PubKeyAminoName   = "tendermint/PubKeyEd25519"
+)
+
+// PublicKey is a device public key in the encodings used by Cosmos SDK keyrings, genesis files and transactions
+type PublicKey interface {
+	// Bytes returns the raw key as returned by the device
+	Bytes() []byte
+	// TypeURL returns the protobuf type of the key, e.g. /cosmos.crypto.secp256k1.PubKey
+	TypeURL() string
+	// Address returns the 20 bytes account or consensus address of the key
+	Address() []byte
+	// MarshalAny returns the key wrapped in a protobuf google.protobuf.Any
+	MarshalAny() ([]byte, error)
+	// MarshalJSON returns the protobuf JSON of the Any, {"@type":...,"key":...}
+	MarshalJSON() ([]byte, error)
+	// AminoJSON returns the legacy amino JSON of the key, {"type":...,"value":...}
+	AminoJSON() ([]byte, error)
+}
+
+// Secp256k1PubKey is a compressed secp256k1 public key from GetPublicKeySECP256K1
+type Secp256k1PubKey []byte
+
+// Ed25519PubKey is an ed25519 public key from GetPublicKeyED25519
+type Ed25519PubKey []byte
+
+var (
+	_ PublicKey = Secp256k1PubKey(nil)
+	_ PublicKey = Ed25519PubKey(nil)
+)
+
+// NewSecp256k1PubKey checks that bz is a valid compressed secp256k1 public key
+func NewSecp256k1PubKey(bz []byte) (Secp256k1PubKey, error) {
+	if len(bz) != btcec.PubKeyBytesLenCompressed {
+		return nil, fmt.Errorf("expected a %d bytes compressed public key, got %d bytes", btcec.PubKeyBytesLenCompressed, len(bz))
+	}
+	if _, err := btcec.ParsePubKey(bz); err != nil {
+		return nil, err
+	}
+	return Secp256k1PubKey(bz), nil
+}
+
+// NewEd25519PubKey checks that bz is an ed25519 public key
+func NewEd25519PubKey(bz []byte) (Ed25519PubKey, error) {
+	if len(bz) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("expected a %d bytes ed25519 public key, got %d bytes", ed25519.PublicKeySize, len(bz))
+	}
+	return Ed25519PubKey(bz), nil
+}
+
+func (k Secp256k1PubKey) Bytes() []byte {
+	return []byte(k)
+}
+
+func (k Secp256k1PubKey) TypeURL() string {
+	return Secp256k1PubKeyTypeURL
+}
+
+// Address returns ripemd160(sha256(key))
+func (k Secp256k1PubKey) Address() []byte {
+	return cosmosAddress(k)
+}
+
+func (k Secp256k1PubKey) MarshalAny() ([]byte, error) {
+	if _, err := NewSecp256k1PubKey(k); err != nil {
+		return nil, err
+	}
+	return marshalPubKeyAny(k.TypeURL(), k), nil
+}
+
+func (k Secp256k1PubKey) MarshalJSON() ([]byte, error) {
+	if _, err := NewSecp256k1PubKey(k); err != nil {
+		return nil, err
+	}
+	return marshalPubKeyJSON(k.TypeURL(), k)
+}
+
+func (k Secp256k1PubKey) AminoJSON() ([]byte, error) {
+	if _, err := NewSecp256k1PubKey(k); err != nil {
+		return nil, err
+	}
+	return marshalPubKeyAmino(secp256k1PubKeyAminoName, k)
+}
+
+func (k Ed25519PubKey) Bytes() []byte {
+	return []byte(k)
+}
+
+func (k Ed25519PubKey) TypeURL() string {
+	return Ed25519PubKeyTypeURL
+}
+
+// Address returns the first 20 bytes of sha256(key), the consensus address of a validator
+func (k Ed25519PubKey) Address() []byte {
+	hash := sha256.Sum256(k)
+	return hash[:20]
+}
+
+func (k Ed25519PubKey) MarshalAny() ([]byte, error) {
+	if _, err := NewEd25519PubKey(k); err != nil {
+		return nil, err
+	}
+	return marshalPubKeyAny(k.TypeURL(), k), nil
+}
+
+func (k Ed25519PubKey) MarshalJSON() ([]byte, error) {
+	if _, err := NewEd25519PubKey(k); err != nil {
+		return nil, err
+	}
+	return marshalPubKeyJSON(k.TypeURL(), k)
+}
+
+func (k Ed25519PubKey) AminoJSON() ([]byte, error) {
+	if _, err := NewEd25519PubKey(k); err != nil {
+		return nil, err
+	}
+	return marshalPubKeyAmino(ed25519PubKeyAminoName, k)
+}
+
+// UnmarshalAnyPubKey decodes a public key wrapped in a protobuf google.protobuf.Any
+func UnmarshalAnyPubKey(bz []byte) (PublicKey, error) {
+	var typeURL string
+	var value []byte
+
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			return nil, fmt.Errorf("unexpected field %d in Any", num)
+		}
+		field, n := protowire.ConsumeBytes(bz)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+		if num == 1 {
+			typeURL = string(field)
+		} else {
+			value = field
+		}
+	}
+
+	// the key message has a single bytes field
+	num, typ, n := protowire.ConsumeTag(value)
+	if n < 0 {
+		return nil, protowire.ParseError(n)
+	}
+	if num != 1 || typ != protowire.BytesType {
+		return nil, fmt.Errorf("unexpected field %d in %s", num, typeURL)
+	}
+	key, m := protowire.ConsumeBytes(value[n:])
+	if m < 0 {
+		return nil, protowire.ParseError(m)
+	}
+	if n+m != len(value) {
+		return nil, fmt.Errorf("unexpected data after the key in %s", typeURL)
+	}
+
+	return newPublicKey(typeURL, key)
+}
+
+// UnmarshalJSONPubKey decodes the protobuf JSON of a public key Any, {"@type":...,"key":...}
+func UnmarshalJSONPubKey(bz []byte) (PublicKey, error) {
+	var pubkey struct {
+		Type string `json:"@type"`
+		Key  []byte `json:"key"`
+	}
+	if err := json.Unmarshal(bz, &pubkey); err != nil {
+		return nil, err
+	}
+	return newPublicKey(pubkey.Type, pubkey.Key)
+}
+
+func newPublicKey(typeURL string, key []byte) (PublicKey, error) {
+	var pubkey PublicKey
+	var err error
+
+	switch typeURL {
+	case Secp256k1PubKeyTypeURL:
+		pubkey, err = NewSecp256k1PubKey(key)
+	case Ed25519PubKeyTypeURL:
+		pubkey, err = NewEd25519PubKey(key)
+	case "":
+		return nil, errors.New("missing public key type")
+	default:
+		return nil, fmt.Errorf("unsupported public key type %s", typeURL)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return pubkey, nil
+}
+
+func marshalPubKeyAny(typeURL string, key []byte) []byte {
+	var value []byte
+	value = protowire.AppendTag(value, 1, protowire.BytesType)
+	value = protowire.AppendBytes(value, key)
+
+	var wrapped []byte
+	wrapped = appendStringField(wrapped, 1, typeURL)
+	return appendBytesField(wrapped, 2, value)
+}
+
+func marshalPubKeyJSON(typeURL string, key []byte) ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"@type"`
+		Key  []byte `json:"key"`
+	}{typeURL, key})
+}
+
+func marshalPubKeyAmino(name string, key []byte) ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Value []byte `json:"value"`
+	}{name, key})
+}
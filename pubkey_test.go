@@ -0,0 +1,102 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Secp256k1PubKeyEncodings(t *testing.T) {
+	pubkey, err := NewSecp256k1PubKey(testSECP256K1Key().PubKey().SerializeCompressed())
+	require.NoError(t, err)
+
+	bz, err := pubkey.MarshalAny()
+	require.NoError(t, err)
+	expected := append([]byte{0x0a, 31}, "/cosmos.crypto.secp256k1.PubKey"...)
+	expected = append(expected, 0x12, 35, 0x0a, 33)
+	expected = append(expected, pubkey...)
+	assert.Equal(t, expected, bz)
+
+	decoded, err := UnmarshalAnyPubKey(bz)
+	require.NoError(t, err)
+	assert.Equal(t, pubkey, decoded)
+
+	jsonBytes, err := pubkey.MarshalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"@type":"/cosmos.crypto.secp256k1.PubKey","key":"`+base64.StdEncoding.EncodeToString(pubkey)+`"}`, string(jsonBytes))
+
+	decoded, err = UnmarshalJSONPubKey(jsonBytes)
+	require.NoError(t, err)
+	assert.Equal(t, pubkey, decoded)
+
+	amino, err := pubkey.AminoJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"tendermint/PubKeySecp256k1","value":"`+base64.StdEncoding.EncodeToString(pubkey)+`"}`, string(amino))
+
+	assert.Equal(t, cosmosAddress(pubkey), pubkey.Address())
+}
+
+func Test_Ed25519PubKeyEncodings(t *testing.T) {
+	raw := bytes.Repeat([]byte{0x42}, 32)
+	pubkey, err := NewEd25519PubKey(raw)
+	require.NoError(t, err)
+
+	bz, err := pubkey.MarshalAny()
+	require.NoError(t, err)
+	assert.Equal(t, "0a1d2f636f736d6f732e63727970746f2e656432353531392e5075624b657912220a20"+hex.EncodeToString(raw), hex.EncodeToString(bz))
+
+	decoded, err := UnmarshalAnyPubKey(bz)
+	require.NoError(t, err)
+	assert.Equal(t, pubkey, decoded)
+
+	jsonBytes, err := pubkey.MarshalJSON()
+	require.NoError(t, err)
+	decoded, err = UnmarshalJSONPubKey(jsonBytes)
+	require.NoError(t, err)
+	assert.Equal(t, Ed25519PubKeyTypeURL, decoded.TypeURL())
+	assert.Equal(t, raw, decoded.Bytes())
+
+	assert.Len(t, pubkey.Address(), 20)
+}
+
+func Test_PubKeyDecodeErrors(t *testing.T) {
+	_, err := NewSecp256k1PubKey(make([]byte, 33))
+	assert.Error(t, err)
+	_, err = NewEd25519PubKey(make([]byte, 33))
+	assert.Error(t, err)
+
+	_, err = Ed25519PubKey(make([]byte, 31)).MarshalAny()
+	assert.Error(t, err)
+
+	_, err = UnmarshalJSONPubKey([]byte(`{"@type":"/cosmos.crypto.sr25519.PubKey","key":"AA=="}`))
+	assert.Error(t, err)
+	_, err = UnmarshalJSONPubKey([]byte(`{"key":"AA=="}`))
+	assert.Error(t, err)
+
+	bz, err := Ed25519PubKey(make([]byte, 32)).MarshalAny()
+	require.NoError(t, err)
+	_, err = UnmarshalAnyPubKey(bz[:len(bz)-1])
+	assert.Error(t, err)
+	_, err = UnmarshalAnyPubKey(append(bz, 0x18, 1))
+	assert.Error(t, err)
+}
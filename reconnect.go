@@ -0,0 +1,370 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+// ErrDeviceDisconnected is returned by calls made while the device is being reconnected
+var ErrDeviceDisconnected = errors.New("ledger device disconnected")
+
+// ErrExchangeTimeout is returned when the device does not answer within ReconnectOptions.ExchangeTimeout
+var ErrExchangeTimeout = errors.New("ledger device did not answer in time")
+
+// ConnectionEventType identifies a ConnectionEvent
+type ConnectionEventType int
+
+const (
+	// ConnectionEventDisconnected is emitted when a transport failure is detected
+	ConnectionEventDisconnected ConnectionEventType = iota
+	// ConnectionEventConnected is emitted when the same device and app are back
+	ConnectionEventConnected
+	// ConnectionEventMismatch is emitted when a device came back with a different key or app version
+	ConnectionEventMismatch
+)
+
+func (t ConnectionEventType) String() string {
+	switch t {
+	case ConnectionEventDisconnected:
+		return "disconnected"
+	case ConnectionEventConnected:
+		return "connected"
+	case ConnectionEventMismatch:
+		return "mismatch"
+	default:
+		return fmt.Sprintf("ConnectionEventType(%d)", int(t))
+	}
+}
+
+// ConnectionEvent reports a change in the connection of an auto-reconnecting client
+type ConnectionEvent struct {
+	Type ConnectionEventType
+	Time time.Time
+	// Err is the failure that caused a disconnection or mismatch
+	Err error
+}
+
+// ReconnectOptions configures an auto-reconnecting client
+type ReconnectOptions struct {
+	// Wait controls the backoff used while the device is missing
+	Wait WaitOptions
+	// FingerprintPath is the derivation path whose public key identifies the device.
+	// Defaults to 44'/118'/0'/0/0 for the user app and 44'/118'/0'/0'/0' for the validator app.
+	FingerprintPath []uint32
+	// ExchangeTimeout, if set, treats an APDU that takes longer as a transport failure.
+	// Leave it unset for commands that wait for user confirmation.
+	ExchangeTimeout time.Duration
+	// Events, if set, receives connection events. Events are dropped if the channel is full.
+	Events chan<- ConnectionEvent
+}
+
+// reopenFunc waits for the app to be available again and returns its device and fingerprint
+type reopenFunc func(ctx context.Context) (ledger_go.LedgerDevice, []byte, error)
+
+// reconnectingDevice forwards APDUs to the current device and replaces it after transport failures
+type reconnectingDevice struct {
+	opts        ReconnectOptions
+	reopen      reopenFunc
+	fingerprint []byte
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	exchangeMu sync.Mutex
+
+	mu     sync.Mutex
+	device ledger_go.LedgerDevice
+	closed bool
+}
+
+// NewReconnectingCosmosUserApp waits for the Cosmos user app and returns a client that
+// transparently reconnects to the same device after it is unplugged or the app is closed.
+// Calls made while the device is away fail with ErrDeviceDisconnected.
+func NewReconnectingCosmosUserApp(ctx context.Context, opts ReconnectOptions) (*LedgerCosmos, error) {
+	if opts.FingerprintPath == nil {
+		opts.FingerprintPath = []uint32{44, 118, 0, 0, 0}
+	}
+
+	reopen := func(ctx context.Context) (ledger_go.LedgerDevice, []byte, error) {
+		app, err := WaitForCosmosUserApp(ctx, opts.Wait)
+		if err != nil {
+			return nil, nil, err
+		}
+		fingerprint, err := app.fingerprint(opts.FingerprintPath)
+		if err != nil {
+			app.Close()
+			return nil, nil, err
+		}
+		return app.api, fingerprint, nil
+	}
+
+	device, err := newReconnectingDevice(ctx, opts, reopen)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &LedgerCosmos{api: device}
+	if _, err := app.GetVersion(); err != nil {
+		device.Close()
+		return nil, err
+	}
+	return app, nil
+}
+
+// NewReconnectingTendermintValidatorApp waits for the Tendermint validator app and returns a client that
+// transparently reconnects to the same device after it is unplugged or the app is closed.
+// Calls made while the device is away fail with ErrDeviceDisconnected.
+func NewReconnectingTendermintValidatorApp(ctx context.Context, opts ReconnectOptions) (*LedgerTendermintValidator, error) {
+	if opts.FingerprintPath == nil {
+		opts.FingerprintPath = []uint32{44, 118, 0, 0, 0}
+	}
+
+	reopen := func(ctx context.Context) (ledger_go.LedgerDevice, []byte, error) {
+		app, err := WaitForTendermintValidatorApp(ctx, opts.Wait)
+		if err != nil {
+			return nil, nil, err
+		}
+		fingerprint, err := app.fingerprint(opts.FingerprintPath)
+		if err != nil {
+			app.Close()
+			return nil, nil, err
+		}
+		return app.api, fingerprint, nil
+	}
+
+	device, err := newReconnectingDevice(ctx, opts, reopen)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &LedgerTendermintValidator{api: device}
+	if _, err := app.GetVersion(); err != nil {
+		device.Close()
+		return nil, err
+	}
+	return app, nil
+}
+
+func newReconnectingDevice(ctx context.Context, opts ReconnectOptions, reopen reopenFunc) (*reconnectingDevice, error) {
+	device, fingerprint, err := reopen(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	return &reconnectingDevice{
+		opts:        opts,
+		reopen:      reopen,
+		fingerprint: fingerprint,
+		ctx:         loopCtx,
+		cancel:      cancel,
+		device:      device,
+	}, nil
+}
+
+// Exchange sends the command to the current device. Transport failures drop the device
+// and start reconnecting in the background, commands that ledger-go refused to send do not.
+func (d *reconnectingDevice) Exchange(command []byte) ([]byte, error) {
+	d.exchangeMu.Lock()
+	defer d.exchangeMu.Unlock()
+
+	d.mu.Lock()
+	device, closed := d.device, d.closed
+	d.mu.Unlock()
+
+	if closed {
+		return nil, errors.New("ledger device closed")
+	}
+	if device == nil {
+		return nil, ErrDeviceDisconnected
+	}
+
+	response, err, pending := d.exchange(device, command)
+	if err != nil {
+		if _, isStatus := statusWordFromError(err); !isStatus && !isLocalExchangeError(err) {
+			d.disconnect(device, err, pending)
+			return response, fmt.Errorf("%w: %v", ErrDeviceDisconnected, err)
+		}
+	}
+	return response, err
+}
+
+// exchange sends command to device, giving up after ExchangeTimeout. When it gives up,
+// the returned channel is closed once the abandoned device.Exchange call returns.
+func (d *reconnectingDevice) exchange(device ledger_go.LedgerDevice, command []byte) ([]byte, error, <-chan struct{}) {
+	if d.opts.ExchangeTimeout <= 0 {
+		response, err := device.Exchange(command)
+		return response, err, nil
+	}
+
+	type result struct {
+		response []byte
+		err      error
+	}
+	done := make(chan result, 1)
+	pending := make(chan struct{})
+	go func() {
+		defer close(pending)
+		response, err := device.Exchange(command)
+		done <- result{response, err}
+	}()
+
+	timer := time.NewTimer(d.opts.ExchangeTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.response, r.err, nil
+	case <-timer.C:
+		return nil, ErrExchangeTimeout, pending
+	}
+}
+
+// disconnect drops device if it is still the current one and starts the reconnection loop.
+// The device is only closed once pending, the exchange abandoned after a timeout, has returned,
+// so a device that never answers is never closed or reopened while still in use.
+func (d *reconnectingDevice) disconnect(device ledger_go.LedgerDevice, cause error, pending <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.device != device {
+		return
+	}
+	d.device = nil
+
+	d.emit(ConnectionEventDisconnected, cause)
+	go func() {
+		if pending != nil {
+			<-pending
+		}
+		device.Close()
+		d.reconnectLoop()
+	}()
+}
+
+func (d *reconnectingDevice) reconnectLoop() {
+	for {
+		device, fingerprint, err := d.reopen(d.ctx)
+		if d.ctx.Err() != nil {
+			if device != nil {
+				device.Close()
+			}
+			return
+		}
+
+		if err == nil && !bytes.Equal(fingerprint, d.fingerprint) {
+			device.Close()
+			err = errors.New("a different device or app version was connected")
+			d.emit(ConnectionEventMismatch, err)
+		}
+
+		if err != nil {
+			if !d.sleep(d.opts.Wait.withDefaults().MaxInterval) {
+				return
+			}
+			continue
+		}
+
+		d.mu.Lock()
+		if d.closed {
+			d.mu.Unlock()
+			device.Close()
+			return
+		}
+		d.device = device
+		d.emit(ConnectionEventConnected, nil)
+		d.mu.Unlock()
+		return
+	}
+}
+
+// sleep waits for duration and returns false if the device was closed meanwhile
+func (d *reconnectingDevice) sleep(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-d.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (d *reconnectingDevice) emit(eventType ConnectionEventType, err error) {
+	if d.opts.Events == nil {
+		return
+	}
+	select {
+	case d.opts.Events <- ConnectionEvent{Type: eventType, Time: time.Now(), Err: err}:
+	default:
+	}
+}
+
+// Close stops reconnecting and closes the current device
+func (d *reconnectingDevice) Close() error {
+	d.cancel()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	if d.device == nil {
+		return nil
+	}
+	err := d.device.Close()
+	d.device = nil
+	return err
+}
+
+// fingerprint identifies the device and app by app version and a public key
+func (ledger *LedgerCosmos) fingerprint(bip32Path []uint32) ([]byte, error) {
+	version, err := ledger.GetVersion()
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := ledger.GetPublicKeySECP256K1(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{version.Major, version.Minor, version.Patch}, pubkey...), nil
+}
+
+// fingerprint identifies the device and app by app version and a public key
+func (ledger *LedgerTendermintValidator) fingerprint(bip32Path []uint32) ([]byte, error) {
+	ledger.deviceMu.Lock()
+	defer ledger.deviceMu.Unlock()
+
+	version, err := ledger.getVersion()
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := ledger.getPublicKeyED25519(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{version.Major, version.Minor, version.Patch}, pubkey...), nil
+}
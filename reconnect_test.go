@@ -0,0 +1,216 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+// fakeCosmosUserApp answers the commands used to identify the Cosmos user app
+func fakeCosmosUserApp(pubkey []byte, unplugged *atomic.Bool) *fakeDevice {
+	return newFakeDevice(func(apdu []byte) ([]byte, error) {
+		if unplugged != nil && unplugged.Load() {
+			return nil, errors.New("hidapi: failed to write")
+		}
+		switch {
+		case apdu[0] == dashboardCLA:
+			return appNameResponse("Cosmos"), nil
+		case apdu[1] == userINSGetVersion:
+			return []byte{0, 2, 30, 0}, nil
+		case apdu[1] == userINSGetAddrSecp256k1:
+			return append(append([]byte{}, pubkey...), "cosmos1xyz"...), nil
+		}
+		return nil, apduError(swINSNotSupported)
+	})
+}
+
+func Test_ReconnectingCosmosUserApp(t *testing.T) {
+	keyA := bytes.Repeat([]byte{0xAA}, 33)
+	keyB := bytes.Repeat([]byte{0xBB}, 33)
+
+	var unplugged atomic.Bool
+	var mu sync.Mutex
+	devices := []*fakeDevice{
+		fakeCosmosUserApp(keyA, &unplugged),
+		fakeCosmosUserApp(keyB, nil),
+		fakeCosmosUserApp(keyA, nil),
+	}
+	useFakeAdmin(t, func() (ledger_go.LedgerDevice, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(devices) == 0 {
+			return nil, errors.New("no device")
+		}
+		device := devices[0]
+		devices = devices[1:]
+		return device, nil
+	})
+
+	events := make(chan ConnectionEvent, 10)
+	app, err := NewReconnectingCosmosUserApp(context.Background(), ReconnectOptions{
+		Wait:   fastWait,
+		Events: events,
+	})
+	require.NoError(t, err)
+	defer app.Close()
+
+	unplugged.Store(true)
+	_, err = app.GetVersion()
+	require.ErrorIs(t, err, ErrDeviceDisconnected)
+
+	nextEvent := func() ConnectionEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for connection event")
+			return ConnectionEvent{}
+		}
+	}
+
+	assert.Equal(t, ConnectionEventDisconnected, nextEvent().Type)
+	assert.Equal(t, ConnectionEventMismatch, nextEvent().Type)
+	assert.Equal(t, ConnectionEventConnected, nextEvent().Type)
+
+	version, err := app.GetVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "2.30.0", version.String())
+}
+
+func Test_ReconnectingTendermintValidatorApp_StatusErrorsKeepConnection(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		switch {
+		case apdu[0] == dashboardCLA:
+			return appNameResponse("Tendermint"), nil
+		case apdu[1] == validatorINSGetVersion:
+			return []byte{0, 0, 9, 0}, nil
+		case apdu[1] == validatorINSPublicKeyED25519:
+			return bytes.Repeat([]byte{1}, 32), nil
+		}
+		return nil, apduError(swDataInvalid)
+	})
+	useFakeAdmin(t, func() (ledger_go.LedgerDevice, error) {
+		return device, nil
+	})
+
+	events := make(chan ConnectionEvent, 10)
+	app, err := NewReconnectingTendermintValidatorApp(context.Background(), ReconnectOptions{
+		Wait:   fastWait,
+		Events: events,
+	})
+	require.NoError(t, err)
+
+	_, err = app.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrDeviceDisconnected)
+	assert.Empty(t, events)
+
+	require.NoError(t, app.Close())
+	assert.True(t, device.IsClosed())
+}
+
+func Test_ReconnectingDevice_TimeoutWaitsBeforeClose(t *testing.T) {
+	release := make(chan struct{})
+	var hang atomic.Bool
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		if hang.Load() {
+			<-release
+			return nil, errors.New("hidapi: failed to read")
+		}
+		switch {
+		case apdu[0] == dashboardCLA:
+			return appNameResponse("Tendermint"), nil
+		case apdu[1] == validatorINSGetVersion:
+			return []byte{0, 0, 9, 0}, nil
+		case apdu[1] == validatorINSPublicKeyED25519:
+			return bytes.Repeat([]byte{1}, 32), nil
+		}
+		return nil, apduError(swINSNotSupported)
+	})
+	useFakeAdmin(t, func() (ledger_go.LedgerDevice, error) {
+		return device, nil
+	})
+
+	events := make(chan ConnectionEvent, 10)
+	app, err := NewReconnectingTendermintValidatorApp(context.Background(), ReconnectOptions{
+		Wait:            fastWait,
+		ExchangeTimeout: 20 * time.Millisecond,
+		Events:          events,
+	})
+	require.NoError(t, err)
+	defer app.Close()
+
+	hang.Store(true)
+	_, err = app.GetVersion()
+	require.ErrorIs(t, err, ErrDeviceDisconnected)
+	assert.Equal(t, ConnectionEventDisconnected, (<-events).Type)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, device.IsClosed(), "device closed while an exchange was still running")
+
+	hang.Store(false)
+	close(release)
+	assert.Eventually(t, device.IsClosed, time.Second, 5*time.Millisecond)
+	select {
+	case event := <-events:
+		assert.Equal(t, ConnectionEventConnected, event.Type)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for connection event")
+	}
+}
+
+func Test_ReconnectingDevice_LocalErrorsKeepConnection(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		switch {
+		case apdu[0] == dashboardCLA:
+			return appNameResponse("Tendermint"), nil
+		case apdu[1] == validatorINSGetVersion:
+			return []byte{0, 0, 9, 0}, nil
+		case apdu[1] == validatorINSPublicKeyED25519:
+			return bytes.Repeat([]byte{1}, 32), nil
+		}
+		return nil, errors.New("APDU[data length] mismatch")
+	})
+	useFakeAdmin(t, func() (ledger_go.LedgerDevice, error) {
+		return device, nil
+	})
+
+	events := make(chan ConnectionEvent, 10)
+	app, err := NewReconnectingTendermintValidatorApp(context.Background(), ReconnectOptions{
+		Wait:   fastWait,
+		Events: events,
+	})
+	require.NoError(t, err)
+	defer app.Close()
+
+	_, err = app.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	require.ErrorContains(t, err, "APDU[data length] mismatch")
+	assert.NotErrorIs(t, err, ErrDeviceDisconnected)
+	assert.False(t, isTransportError(err))
+	assert.Empty(t, events)
+	assert.False(t, device.IsClosed())
+}
@@ -0,0 +1,390 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gtank/merlin"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SecretConnection framing and key schedule, as implemented by CometBFT p2p/conn
+const (
+	secretConnDataLenSize   = 4
+	secretConnDataMaxSize   = 1024
+	secretConnFrameSize     = secretConnDataLenSize + secretConnDataMaxSize
+	secretConnSealedSize    = secretConnFrameSize + chacha20poly1305.Overhead
+	secretConnKeySize       = chacha20poly1305.KeySize
+	secretConnChallengeSize = 32
+
+	secretConnTranscriptLabel = "TENDERMINT_SECRET_CONNECTION_TRANSCRIPT_HASH"
+	secretConnLowerKeyLabel   = "EPHEMERAL_LOWER_PUBLIC_KEY"
+	secretConnUpperKeyLabel   = "EPHEMERAL_UPPER_PUBLIC_KEY"
+	secretConnDHSecretLabel   = "DH_SECRET"
+	secretConnMacLabel        = "SECRET_CONNECTION_MAC"
+	secretConnKDFInfo         = "TENDERMINT_SECRET_CONNECTION_KEY_AND_CHALLENGE_GEN"
+)
+
+// ErrSecretConnAuth is returned when the peer of a SecretConnection fails to authenticate
+var ErrSecretConnAuth = errors.New("secret connection: peer authentication failed")
+
+// secretConnection is an authenticated and encrypted connection compatible with CometBFT's SecretConnection
+type secretConnection struct {
+	conn      net.Conn
+	remotePub ed25519.PublicKey
+
+	recvMu    sync.Mutex
+	recvAead  cipher.AEAD
+	recvNonce [chacha20poly1305.NonceSize]byte
+	recvBuf   []byte
+
+	sendMu    sync.Mutex
+	sendAead  cipher.AEAD
+	sendNonce [chacha20poly1305.NonceSize]byte
+}
+
+// newSecretConnection performs the SecretConnection handshake over conn, authenticating with key
+func newSecretConnection(conn net.Conn, key ed25519.PrivateKey) (*secretConnection, error) {
+	locEphPub, locEphPriv, err := generateEphemeralKey()
+	if err != nil {
+		return nil, err
+	}
+
+	remEphPub, err := exchangeValues(conn, locEphPub[:], func(w io.Writer, v []byte) error {
+		return writeDelimited(w, appendBytesField(nil, 1, v))
+	}, readEphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+
+	loEph, hiEph := locEphPub[:], remEphPub
+	if bytes.Compare(loEph, hiEph) > 0 {
+		loEph, hiEph = hiEph, loEph
+	}
+	locIsLeast := bytes.Equal(locEphPub[:], loEph)
+
+	transcript := merlin.NewTranscript(secretConnTranscriptLabel)
+	transcript.AppendMessage([]byte(secretConnLowerKeyLabel), loEph)
+	transcript.AppendMessage([]byte(secretConnUpperKeyLabel), hiEph)
+
+	dhSecret, err := curve25519.X25519(locEphPriv[:], remEphPub)
+	if err != nil {
+		return nil, fmt.Errorf("secret connection: %w", err)
+	}
+	transcript.AppendMessage([]byte(secretConnDHSecretLabel), dhSecret)
+
+	recvSecret, sendSecret, err := deriveSecrets(dhSecret, locIsLeast)
+	if err != nil {
+		return nil, err
+	}
+	challenge := transcript.ExtractBytes([]byte(secretConnMacLabel), secretConnChallengeSize)
+
+	sc := &secretConnection{conn: conn}
+	if sc.recvAead, err = chacha20poly1305.New(recvSecret); err != nil {
+		return nil, err
+	}
+	if sc.sendAead, err = chacha20poly1305.New(sendSecret); err != nil {
+		return nil, err
+	}
+
+	// both sides sign the challenge with their long term key, over the encrypted channel
+	locAuth := encodeAuthSig(key.Public().(ed25519.PublicKey), ed25519.Sign(key, challenge))
+	remAuth, err := exchangeValues(sc, locAuth, writeDelimited, func(r io.Reader) ([]byte, error) {
+		return readDelimited(r, secretConnFrameSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	remPub, remSig, err := decodeAuthSig(remAuth)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(remPub, challenge, remSig) {
+		return nil, ErrSecretConnAuth
+	}
+	sc.remotePub = remPub
+	return sc, nil
+}
+
+// RemotePubKey returns the authenticated long term key of the peer
+func (sc *secretConnection) RemotePubKey() ed25519.PublicKey {
+	return sc.remotePub
+}
+
+func (sc *secretConnection) Write(data []byte) (int, error) {
+	sc.sendMu.Lock()
+	defer sc.sendMu.Unlock()
+
+	written := 0
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > secretConnDataMaxSize {
+			chunk = chunk[:secretConnDataMaxSize]
+		}
+		data = data[len(chunk):]
+
+		var frame [secretConnFrameSize]byte
+		binary.LittleEndian.PutUint32(frame[:secretConnDataLenSize], uint32(len(chunk)))
+		copy(frame[secretConnDataLenSize:], chunk)
+
+		sealed := sc.sendAead.Seal(nil, sc.sendNonce[:], frame[:], nil)
+		incrementNonce(&sc.sendNonce)
+		if _, err := sc.conn.Write(sealed); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+func (sc *secretConnection) Read(data []byte) (int, error) {
+	sc.recvMu.Lock()
+	defer sc.recvMu.Unlock()
+
+	if len(sc.recvBuf) > 0 {
+		n := copy(data, sc.recvBuf)
+		sc.recvBuf = sc.recvBuf[n:]
+		return n, nil
+	}
+
+	sealed := make([]byte, secretConnSealedSize)
+	if _, err := io.ReadFull(sc.conn, sealed); err != nil {
+		return 0, err
+	}
+	frame, err := sc.recvAead.Open(nil, sc.recvNonce[:], sealed, nil)
+	if err != nil {
+		return 0, fmt.Errorf("secret connection: failed to decrypt frame: %w", err)
+	}
+	incrementNonce(&sc.recvNonce)
+
+	length := binary.LittleEndian.Uint32(frame[:secretConnDataLenSize])
+	if length > secretConnDataMaxSize {
+		return 0, errors.New("secret connection: frame length exceeds the maximum")
+	}
+	chunk := frame[secretConnDataLenSize : secretConnDataLenSize+length]
+	n := copy(data, chunk)
+	sc.recvBuf = chunk[n:]
+	return n, nil
+}
+
+func (sc *secretConnection) Close() error                       { return sc.conn.Close() }
+func (sc *secretConnection) LocalAddr() net.Addr                { return sc.conn.LocalAddr() }
+func (sc *secretConnection) RemoteAddr() net.Addr               { return sc.conn.RemoteAddr() }
+func (sc *secretConnection) SetDeadline(t time.Time) error      { return sc.conn.SetDeadline(t) }
+func (sc *secretConnection) SetReadDeadline(t time.Time) error  { return sc.conn.SetReadDeadline(t) }
+func (sc *secretConnection) SetWriteDeadline(t time.Time) error { return sc.conn.SetWriteDeadline(t) }
+
+func generateEphemeralKey() (pub, priv [32]byte, err error) {
+	if _, err = rand.Read(priv[:]); err != nil {
+		return pub, priv, err
+	}
+	pubSlice, err := curve25519.X25519(priv[:], curve25519.Basepoint)
+	copy(pub[:], pubSlice)
+	return pub, priv, err
+}
+
+func readEphemeralKey(r io.Reader) ([]byte, error) {
+	msg, err := readDelimited(r, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// gogoproto BytesValue{value = 1}
+	num, typ, n := protowire.ConsumeTag(msg)
+	if n < 0 || num != 1 || typ != protowire.BytesType {
+		return nil, errors.New("secret connection: malformed ephemeral key")
+	}
+	key, m := protowire.ConsumeBytes(msg[n:])
+	if m < 0 || n+m != len(msg) || len(key) != 32 {
+		return nil, errors.New("secret connection: malformed ephemeral key")
+	}
+	return key, nil
+}
+
+// deriveSecrets expands the DH secret into the receive and send keys
+func deriveSecrets(dhSecret []byte, locIsLeast bool) (recvSecret, sendSecret []byte, err error) {
+	res := make([]byte, 2*secretConnKeySize+32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, dhSecret, nil, []byte(secretConnKDFInfo)), res); err != nil {
+		return nil, nil, err
+	}
+
+	if locIsLeast {
+		return res[:secretConnKeySize], res[secretConnKeySize : 2*secretConnKeySize], nil
+	}
+	return res[secretConnKeySize : 2*secretConnKeySize], res[:secretConnKeySize], nil
+}
+
+// incrementNonce increments the little endian counter in the last 8 bytes of the nonce
+func incrementNonce(nonce *[chacha20poly1305.NonceSize]byte) {
+	counter := binary.LittleEndian.Uint64(nonce[4:])
+	binary.LittleEndian.PutUint64(nonce[4:], counter+1)
+}
+
+// exchangeValues writes a value while reading the peer's, both sides send first
+func exchangeValues(rw io.ReadWriter, value []byte, write func(io.Writer, []byte) error, read func(io.Reader) ([]byte, error)) ([]byte, error) {
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- write(rw, value)
+	}()
+
+	received, err := read(rw)
+	if werr := <-writeErr; err == nil {
+		err = werr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("secret connection handshake: %w", err)
+	}
+	return received, nil
+}
+
+// encodeAuthSig encodes a p2p AuthSigMessage{pub_key = 1, sig = 2}
+func encodeAuthSig(pub ed25519.PublicKey, sig []byte) []byte {
+	var b []byte
+	b = appendBytesField(b, 1, encodeCryptoPublicKey(pub))
+	return appendBytesField(b, 2, sig)
+}
+
+func decodeAuthSig(msg []byte) (ed25519.PublicKey, []byte, error) {
+	var pub ed25519.PublicKey
+	var sig []byte
+	err := consumeFields(msg, func(num protowire.Number, value []byte, _ uint64) error {
+		switch num {
+		case 1:
+			key, err := decodeCryptoPublicKey(value)
+			pub = key
+			return err
+		case 2:
+			sig = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pub) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return nil, nil, ErrSecretConnAuth
+	}
+	return pub, sig, nil
+}
+
+// encodeCryptoPublicKey encodes a tendermint.crypto.PublicKey with the ed25519 variant
+func encodeCryptoPublicKey(pub ed25519.PublicKey) []byte {
+	b := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, pub)
+}
+
+func decodeCryptoPublicKey(msg []byte) (ed25519.PublicKey, error) {
+	var pub ed25519.PublicKey
+	err := consumeFields(msg, func(num protowire.Number, value []byte, _ uint64) error {
+		if num != 1 {
+			return fmt.Errorf("unsupported public key type %d", num)
+		}
+		pub = ed25519.PublicKey(value)
+		return nil
+	})
+	return pub, err
+}
+
+// writeDelimited writes a uvarint length prefixed message
+func writeDelimited(w io.Writer, msg []byte) error {
+	_, err := w.Write(protowire.AppendBytes(nil, msg))
+	return err
+}
+
+// readDelimited reads a uvarint length prefixed message of at most maxSize bytes.
+// Unless r is buffered it never reads past the message.
+func readDelimited(r io.Reader, maxSize int) ([]byte, error) {
+	byteReader, ok := r.(io.ByteReader)
+	if !ok {
+		byteReader = singleByteReader{r}
+	}
+	length, err := binary.ReadUvarint(byteReader)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(maxSize) {
+		return nil, fmt.Errorf("message of %d bytes exceeds the maximum of %d", length, maxSize)
+	}
+
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// singleByteReader reads one byte at a time, so that nothing after a length prefix is consumed
+type singleByteReader struct {
+	r io.Reader
+}
+
+func (s singleByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(s.r, b[:])
+	return b[0], err
+}
+
+// consumeFields calls fn for every length delimited and varint field of msg, with either its
+// bytes or its value, and skips the fixed size ones
+func consumeFields(msg []byte, fn func(num protowire.Number, value []byte, varint uint64) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		var value []byte
+		var varint uint64
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(msg)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		if typ != protowire.BytesType && typ != protowire.VarintType {
+			continue
+		}
+		if err := fn(num, value, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConnKey(name string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte(name))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// secretConnPair returns both ends of a SecretConnection over an in memory pipe
+func secretConnPair(t *testing.T, keyA, keyB ed25519.PrivateKey) (*secretConnection, *secretConnection) {
+	connA, connB := net.Pipe()
+	t.Cleanup(func() {
+		connA.Close()
+		connB.Close()
+	})
+
+	type result struct {
+		sc  *secretConnection
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		sc, err := newSecretConnection(connB, keyB)
+		done <- result{sc, err}
+	}()
+
+	scA, err := newSecretConnection(connA, keyA)
+	require.NoError(t, err)
+	b := <-done
+	require.NoError(t, b.err)
+	return scA, b.sc
+}
+
+func Test_SecretConnectionHandshake(t *testing.T) {
+	keyA, keyB := testConnKey("node"), testConnKey("signer")
+	scA, scB := secretConnPair(t, keyA, keyB)
+
+	assert.Equal(t, keyB.Public(), scA.RemotePubKey())
+	assert.Equal(t, keyA.Public(), scB.RemotePubKey())
+}
+
+func Test_SecretConnectionRoundTrip(t *testing.T) {
+	scA, scB := secretConnPair(t, testConnKey("node"), testConnKey("signer"))
+
+	// spans several frames, the last one partially filled
+	message := bytes.Repeat([]byte("0123456789"), 250)
+	go func() {
+		_, _ = scA.Write(message)
+	}()
+
+	received := make([]byte, len(message))
+	_, err := io.ReadFull(scB, received)
+	require.NoError(t, err)
+	assert.Equal(t, message, received)
+
+	go func() {
+		_ = writeDelimited(scB, []byte("pong"))
+	}()
+	reply, err := readDelimited(scA, 16)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("pong"), reply)
+}
+
+func Test_SecretConnectionTamperedFrame(t *testing.T) {
+	connA, connB := net.Pipe()
+	defer connA.Close()
+	defer connB.Close()
+
+	go func() {
+		sc, err := newSecretConnection(connB, testConnKey("signer"))
+		if err == nil {
+			_, _ = sc.Write([]byte("hello"))
+		}
+	}()
+
+	// frames are authenticated, one opened out of sequence is rejected
+	scA, err := newSecretConnection(connA, testConnKey("node"))
+	require.NoError(t, err)
+	scA.recvNonce[4]++
+
+	_, err = scA.Read(make([]byte, 5))
+	assert.ErrorContains(t, err, "failed to decrypt frame")
+}
+
+func Test_DecodeAuthSig(t *testing.T) {
+	key := testConnKey("signer")
+	pub := key.Public().(ed25519.PublicKey)
+	signature := ed25519.Sign(key, []byte("challenge"))
+
+	decodedPub, decodedSig, err := decodeAuthSig(encodeAuthSig(pub, signature))
+	require.NoError(t, err)
+	assert.Equal(t, pub, decodedPub)
+	assert.Equal(t, signature, decodedSig)
+
+	_, _, err = decodeAuthSig(encodeAuthSig(pub, signature[:32]))
+	assert.ErrorIs(t, err, ErrSecretConnAuth)
+
+	_, _, err = decodeAuthSig(appendBytesField(nil, 2, signature))
+	assert.ErrorIs(t, err, ErrSecretConnAuth)
+}
+
+func Test_ReadDelimitedMaxSize(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeDelimited(&buf, make([]byte, 100)))
+
+	_, err := readDelimited(&buf, 99)
+	assert.ErrorContains(t, err, "exceeds the maximum")
+}
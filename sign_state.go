@@ -0,0 +1,393 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Steps of a height and round, in signing order, as in CometBFT's FilePV
+const (
+	StepNone      int8 = 0
+	StepPropose   int8 = 1
+	StepPrevote   int8 = 2
+	StepPrecommit int8 = 3
+)
+
+var (
+	// ErrHRSRegression is returned when a message does not advance the last signed height, round and step
+	ErrHRSRegression = errors.New("sign state: height/round/step regression")
+	// ErrConflictingSignBytes is returned when a different message is signed at the last signed height, round and step
+	ErrConflictingSignBytes = errors.New("sign state: conflicting sign bytes at the last signed height/round/step")
+)
+
+// SignState is the last height, round and step signed by a validator
+type SignState struct {
+	Height int64
+	Round  int32
+	Step   int8
+	// SignBytesHash is the sha256 of the last signed sign bytes
+	SignBytesHash []byte
+}
+
+// compare orders states by height, round and step
+func (s SignState) compare(other SignState) int {
+	switch {
+	case s.Height != other.Height:
+		return compareInt64(s.Height, other.Height)
+	case s.Round != other.Round:
+		return compareInt64(int64(s.Round), int64(other.Round))
+	default:
+		return compareInt64(int64(s.Step), int64(other.Step))
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// StateSigner guards a validator signer against double signing. It keeps the last signed height,
+// round and step in a file and refuses to sign a vote or proposal that does not advance them,
+// except for the exact same sign bytes which can be signed again, e.g. after a restart.
+type StateSigner struct {
+	signer    ValidatorSigner
+	stateFile string
+
+	mu    sync.Mutex
+	state SignState
+}
+
+var _ ValidatorSigner = (*StateSigner)(nil)
+
+// NewStateSigner wraps signer, usually a *LedgerTendermintValidator, with the state kept in stateFile.
+// The file is created with an empty state if it does not exist.
+func NewStateSigner(signer ValidatorSigner, stateFile string) (*StateSigner, error) {
+	s := &StateSigner{signer: signer, stateFile: stateFile}
+
+	state, err := readSignState(stateFile)
+	switch {
+	case err == nil:
+		s.state = state
+	case errors.Is(err, os.ErrNotExist):
+		if err := writeSignState(stateFile, s.state); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	return s, nil
+}
+
+// State returns the last signed height, round and step
+func (s *StateSigner) State() SignState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Import replaces the state, e.g. with ReadPrivValidatorState when migrating from a CometBFT FilePV.
+// A state behind the current one is refused.
+func (s *StateSigner) Import(state SignState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state.compare(s.state) < 0 {
+		return fmt.Errorf("%w: importing %d/%d/%d over %d/%d/%d", ErrHRSRegression,
+			state.Height, state.Round, state.Step, s.state.Height, s.state.Round, s.state.Step)
+	}
+	if err := writeSignState(s.stateFile, state); err != nil {
+		return err
+	}
+	s.state = state
+	return nil
+}
+
+func (s *StateSigner) GetPublicKeyED25519(bip32Path []uint32) ([]byte, error) {
+	return s.signer.GetPublicKeyED25519(bip32Path)
+}
+
+// SignED25519 checks message against the state and signs it. The new state is persisted before
+// the message is sent to the device, so that a crash can never lead to a second signature.
+func (s *StateSigner) SignED25519(bip32Path []uint32, message []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, err := decodeSignBytes(message)
+	if err != nil {
+		return nil, err
+	}
+
+	// vote extensions are signed right after their precommit and do not move the state
+	if msg.Extension {
+		if msg.Height != s.state.Height || msg.Round != s.state.Round || s.state.Step != StepPrecommit {
+			return nil, fmt.Errorf("%w: vote extension for %d/%d without its precommit", ErrHRSRegression, msg.Height, msg.Round)
+		}
+		return s.signer.SignED25519(bip32Path, message)
+	}
+
+	hash := sha256.Sum256(message)
+	next := SignState{Height: msg.Height, Round: msg.Round, Step: msg.step(), SignBytesHash: hash[:]}
+
+	switch next.compare(s.state) {
+	case -1:
+		return nil, fmt.Errorf("%w: %d/%d/%d after %d/%d/%d", ErrHRSRegression,
+			next.Height, next.Round, next.Step, s.state.Height, s.state.Round, s.state.Step)
+	case 0:
+		if !bytes.Equal(next.SignBytesHash, s.state.SignBytesHash) {
+			return nil, ErrConflictingSignBytes
+		}
+	default:
+		if err := writeSignState(s.stateFile, next); err != nil {
+			return nil, err
+		}
+		s.state = next
+	}
+
+	return s.signer.SignED25519(bip32Path, message)
+}
+
+// signBytes is the content of the canonical vote, proposal or vote extension signed by validators
+type signBytes struct {
+	Type      SignedMsgType
+	Height    int64
+	Round     int32
+	ChainID   string
+	Extension bool
+}
+
+func (m signBytes) step() int8 {
+	switch m.Type {
+	case ProposalType:
+		return StepPropose
+	case PrevoteType:
+		return StepPrevote
+	case PrecommitType:
+		return StepPrecommit
+	default:
+		return StepNone
+	}
+}
+
+// decodeSignBytes decodes the length prefixed CanonicalVote, CanonicalProposal or
+// CanonicalVoteExtension built by SignBytes
+func decodeSignBytes(message []byte) (signBytes, error) {
+	var m signBytes
+
+	canonical, n := protowire.ConsumeBytes(message)
+	if n < 0 || n != len(message) {
+		return m, fmt.Errorf("%w: expected a length prefixed canonical message", ErrInvalidSignBytes)
+	}
+
+	fields := map[protowire.Number][]byte{}
+	wireTypes := map[protowire.Number]protowire.Type{}
+	for len(canonical) > 0 {
+		num, typ, n := protowire.ConsumeTag(canonical)
+		if n < 0 {
+			return m, fmt.Errorf("%w: %v", ErrInvalidSignBytes, protowire.ParseError(n))
+		}
+		canonical = canonical[n:]
+		wireTypes[num] = typ
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			var value uint64
+			value, n = protowire.ConsumeVarint(canonical)
+			m.Type = SignedMsgType(value)
+		case (num == 2 || num == 3) && typ == protowire.Fixed64Type:
+			var value uint64
+			value, n = protowire.ConsumeFixed64(canonical)
+			if num == 2 {
+				m.Height = int64(value)
+			} else {
+				m.Round = int32(int64(value))
+			}
+		case typ == protowire.BytesType:
+			fields[num], n = protowire.ConsumeBytes(canonical)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, canonical)
+		}
+		if n < 0 {
+			return m, fmt.Errorf("%w: %v", ErrInvalidSignBytes, protowire.ParseError(n))
+		}
+		canonical = canonical[n:]
+	}
+
+	// vote extensions have no type, their first field is the extension, omitted when empty
+	if _, typed := wireTypes[1]; !typed || wireTypes[1] == protowire.BytesType {
+		if !isCanonicalVoteExtension(wireTypes) {
+			return m, fmt.Errorf("%w: expected a canonical vote extension", ErrInvalidSignBytes)
+		}
+		m.Extension = true
+	}
+
+	switch {
+	case m.Extension:
+		m.ChainID = string(fields[4])
+	case m.Type == PrevoteType || m.Type == PrecommitType:
+		m.ChainID = string(fields[6])
+	case m.Type == ProposalType:
+		m.ChainID = string(fields[7])
+	default:
+		return m, fmt.Errorf("%w: unsupported message type %d", ErrInvalidSignBytes, m.Type)
+	}
+	return m, nil
+}
+
+// isCanonicalVoteExtension reports whether the fields are those of a CanonicalVoteExtension:
+// the extension, sfixed64 height and round, and the chain ID
+func isCanonicalVoteExtension(wireTypes map[protowire.Number]protowire.Type) bool {
+	expected := map[protowire.Number]protowire.Type{
+		1: protowire.BytesType,
+		2: protowire.Fixed64Type,
+		3: protowire.Fixed64Type,
+		4: protowire.BytesType,
+	}
+	for num, typ := range wireTypes {
+		if want, ok := expected[num]; !ok || typ != want {
+			return false
+		}
+	}
+	// like the extension, an empty chain ID is omitted, but a signed height never is
+	_, hasHeight := wireTypes[2]
+	return hasHeight
+}
+
+// signStateFile is the JSON of the state file
+type signStateFile struct {
+	Height        int64  `json:"height,string"`
+	Round         int32  `json:"round"`
+	Step          int8   `json:"step"`
+	SignBytesHash string `json:"sign_bytes_hash,omitempty"`
+}
+
+func readSignState(file string) (SignState, error) {
+	bz, err := os.ReadFile(file)
+	if err != nil {
+		return SignState{}, err
+	}
+
+	var stored signStateFile
+	if err := json.Unmarshal(bz, &stored); err != nil {
+		return SignState{}, fmt.Errorf("invalid sign state file %s: %w", file, err)
+	}
+	hash, err := hex.DecodeString(stored.SignBytesHash)
+	if err != nil {
+		return SignState{}, fmt.Errorf("invalid sign state file %s: %w", file, err)
+	}
+	if len(hash) == 0 {
+		hash = nil
+	}
+	return SignState{Height: stored.Height, Round: stored.Round, Step: stored.Step, SignBytesHash: hash}, nil
+}
+
+// writeSignState atomically replaces file with state, synced to disk before it returns
+func writeSignState(file string, state SignState) (rerr error) {
+	bz, err := json.MarshalIndent(signStateFile{
+		Height:        state.Height,
+		Round:         state.Round,
+		Step:          state.Step,
+		SignBytesHash: hex.EncodeToString(state.SignBytesHash),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(file)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rerr != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := tmp.Chmod(0o600); err != nil {
+		return err
+	}
+	if _, err := tmp.Write(bz); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return err
+	}
+
+	// the rename itself is only durable once the directory is synced
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// ReadPrivValidatorState reads the state of a CometBFT FilePV, priv_validator_state.json
+func ReadPrivValidatorState(file string) (SignState, error) {
+	bz, err := os.ReadFile(file)
+	if err != nil {
+		return SignState{}, err
+	}
+
+	var pvState struct {
+		Height    int64  `json:"height,string"`
+		Round     int32  `json:"round"`
+		Step      int8   `json:"step"`
+		SignBytes string `json:"signbytes"`
+	}
+	if err := json.Unmarshal(bz, &pvState); err != nil {
+		return SignState{}, fmt.Errorf("invalid priv_validator_state %s: %w", file, err)
+	}
+	if pvState.Step < StepNone || pvState.Step > StepPrecommit {
+		return SignState{}, fmt.Errorf("invalid priv_validator_state %s: unknown step %d", file, pvState.Step)
+	}
+
+	state := SignState{Height: pvState.Height, Round: pvState.Round, Step: pvState.Step}
+	if pvState.SignBytes != "" {
+		raw, err := hex.DecodeString(pvState.SignBytes)
+		if err != nil {
+			return SignState{}, fmt.Errorf("invalid priv_validator_state %s: %w", file, err)
+		}
+		hash := sha256.Sum256(raw)
+		state.SignBytesHash = hash[:]
+	}
+	return state, nil
+}
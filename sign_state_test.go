@@ -0,0 +1,229 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func newTestStateSigner(t *testing.T) (*StateSigner, *fakeDevice, string) {
+	device := fakeSigningValidatorApp(testED25519Key(), nil)
+	stateFile := filepath.Join(t.TempDir(), "sign_state.json")
+	signer, err := NewStateSigner(&LedgerTendermintValidator{api: device}, stateFile)
+	require.NoError(t, err)
+	return signer, device, stateFile
+}
+
+func testVote(voteType SignedMsgType, height int64, round int32) Vote {
+	return Vote{
+		Type:      voteType,
+		Height:    height,
+		Round:     round,
+		BlockID:   testBlockID,
+		Timestamp: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+}
+
+func Test_StateSignerProgression(t *testing.T) {
+	signer, _, _ := newTestStateSigner(t)
+	path := []uint32{44, 118, 0, 0, 0}
+	pub := testED25519Key().Public().(ed25519.PublicKey)
+
+	proposal := Proposal{Height: 10, Round: 0, POLRound: -1, BlockID: testBlockID}
+	signature, err := signer.SignED25519(path, proposal.SignBytes("test-chain"))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(pub, proposal.SignBytes("test-chain"), signature))
+
+	messages := [][]byte{
+		testVote(PrevoteType, 10, 0).SignBytes("test-chain"),
+		testVote(PrecommitType, 10, 0).SignBytes("test-chain"),
+		VoteExtension{Extension: []byte("ext"), Height: 10, Round: 0}.SignBytes("test-chain"),
+		testVote(PrevoteType, 10, 1).SignBytes("test-chain"),
+		testVote(PrevoteType, 11, 0).SignBytes("test-chain"),
+	}
+	for _, message := range messages {
+		_, err := signer.SignED25519(path, message)
+		require.NoError(t, err)
+	}
+
+	last := sha256.Sum256(messages[4])
+	assert.Equal(t, SignState{Height: 11, Round: 0, Step: StepPrevote, SignBytesHash: last[:]}, signer.State())
+}
+
+func Test_StateSignerRefusesRegressions(t *testing.T) {
+	signer, device, _ := newTestStateSigner(t)
+	path := []uint32{44, 118, 0, 0, 0}
+
+	_, err := signer.SignED25519(path, testVote(PrecommitType, 10, 1).SignBytes("test-chain"))
+	require.NoError(t, err)
+	sent := len(device.Sent())
+
+	regressions := [][]byte{
+		testVote(PrecommitType, 9, 5).SignBytes("test-chain"),
+		testVote(PrecommitType, 10, 0).SignBytes("test-chain"),
+		testVote(PrevoteType, 10, 1).SignBytes("test-chain"),
+		Proposal{Height: 10, Round: 1, POLRound: -1}.SignBytes("test-chain"),
+		VoteExtension{Height: 10, Round: 0}.SignBytes("test-chain"),
+	}
+	for _, message := range regressions {
+		_, err := signer.SignED25519(path, message)
+		assert.ErrorIs(t, err, ErrHRSRegression)
+	}
+
+	// a different precommit at the same height, round and step is a double sign
+	conflicting := testVote(PrecommitType, 10, 1)
+	conflicting.BlockID = BlockID{}
+	_, err = signer.SignED25519(path, conflicting.SignBytes("test-chain"))
+	assert.ErrorIs(t, err, ErrConflictingSignBytes)
+
+	assert.Len(t, device.Sent(), sent, "refused messages must not reach the device")
+}
+
+func Test_StateSignerResignsIdenticalBytes(t *testing.T) {
+	signer, _, stateFile := newTestStateSigner(t)
+	path := []uint32{44, 118, 0, 0, 0}
+	message := testVote(PrevoteType, 10, 0).SignBytes("test-chain")
+
+	first, err := signer.SignED25519(path, message)
+	require.NoError(t, err)
+
+	// the state survives a restart
+	restarted, err := NewStateSigner(&LedgerTendermintValidator{api: fakeSigningValidatorApp(testED25519Key(), nil)}, stateFile)
+	require.NoError(t, err)
+	assert.Equal(t, signer.State(), restarted.State())
+
+	second, err := restarted.SignED25519(path, message)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+}
+
+func Test_StateSignerInvalidSignBytes(t *testing.T) {
+	signer, device, _ := newTestStateSigner(t)
+	path := []uint32{44, 118, 0, 0, 0}
+
+	_, err := signer.SignED25519(path, []byte("not a vote"))
+	assert.ErrorIs(t, err, ErrInvalidSignBytes)
+
+	unknownType := testVote(SignedMsgType(7), 10, 0).SignBytes("test-chain")
+	_, err = signer.SignED25519(path, unknownType)
+	assert.ErrorIs(t, err, ErrInvalidSignBytes)
+	assert.Empty(t, device.Sent())
+}
+
+func Test_StateFile(t *testing.T) {
+	signer, _, stateFile := newTestStateSigner(t)
+
+	_, err := signer.SignED25519([]uint32{44, 118, 0, 0, 0}, testVote(PrecommitType, 42, 3).SignBytes("test-chain"))
+	require.NoError(t, err)
+
+	info, err := os.Stat(stateFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	bz, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(bz), `"height": "42"`)
+	assert.Contains(t, string(bz), `"step": 3`)
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(stateFile))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, os.WriteFile(stateFile, []byte("{"), 0o600))
+	_, err = NewStateSigner(&LedgerTendermintValidator{}, stateFile)
+	assert.ErrorContains(t, err, "invalid sign state file")
+}
+
+func Test_ImportPrivValidatorState(t *testing.T) {
+	signer, _, _ := newTestStateSigner(t)
+	path := []uint32{44, 118, 0, 0, 0}
+
+	message := testVote(PrevoteType, 100, 2).SignBytes("test-chain")
+	pvStateFile := filepath.Join(t.TempDir(), "priv_validator_state.json")
+	pvState := `{
+  "height": "100",
+  "round": 2,
+  "step": 2,
+  "signature": "c2lnbmF0dXJl",
+  "signbytes": "` + strings.ToUpper(hex.EncodeToString(message)) + `"
+}`
+	require.NoError(t, os.WriteFile(pvStateFile, []byte(pvState), 0o600))
+
+	state, err := ReadPrivValidatorState(pvStateFile)
+	require.NoError(t, err)
+	hash := sha256.Sum256(message)
+	assert.Equal(t, SignState{Height: 100, Round: 2, Step: StepPrevote, SignBytesHash: hash[:]}, state)
+
+	require.NoError(t, signer.Import(state))
+	_, err = signer.SignED25519(path, message)
+	assert.NoError(t, err)
+	_, err = signer.SignED25519(path, testVote(PrevoteType, 99, 0).SignBytes("test-chain"))
+	assert.ErrorIs(t, err, ErrHRSRegression)
+
+	assert.ErrorIs(t, signer.Import(SignState{Height: 50}), ErrHRSRegression)
+
+	// a fresh FilePV has no sign bytes
+	require.NoError(t, os.WriteFile(pvStateFile, []byte(`{"height":"0","round":0,"step":0}`), 0o600))
+	state, err = ReadPrivValidatorState(pvStateFile)
+	require.NoError(t, err)
+	assert.Equal(t, SignState{}, state)
+}
+
+func Test_DecodeSignBytes(t *testing.T) {
+	msg, err := decodeSignBytes(testVote(PrecommitType, 5, 1).SignBytes("chain-a"))
+	require.NoError(t, err)
+	assert.Equal(t, signBytes{Type: PrecommitType, Height: 5, Round: 1, ChainID: "chain-a"}, msg)
+
+	msg, err = decodeSignBytes(Proposal{Height: 6, POLRound: -1}.SignBytes("chain-b"))
+	require.NoError(t, err)
+	assert.Equal(t, signBytes{Type: ProposalType, Height: 6, ChainID: "chain-b"}, msg)
+
+	msg, err = decodeSignBytes(VoteExtension{Extension: []byte{1}, Height: 7, Round: 2}.SignBytes("chain-c"))
+	require.NoError(t, err)
+	assert.Equal(t, signBytes{Height: 7, Round: 2, ChainID: "chain-c", Extension: true}, msg)
+
+	// an empty extension omits the first field
+	msg, err = decodeSignBytes(VoteExtension{Height: 7}.SignBytes("chain-c"))
+	require.NoError(t, err)
+	assert.Equal(t, signBytes{Height: 7, ChainID: "chain-c", Extension: true}, msg)
+}
+
+func Test_DecodeSignBytesUntyped(t *testing.T) {
+	// a vote without its type is not mistaken for an extension
+	_, err := decodeSignBytes(testVote(SignedMsgType(0), 5, 1).SignBytes("chain-a"))
+	assert.ErrorIs(t, err, ErrInvalidSignBytes)
+
+	var canonical []byte
+	canonical = protowire.AppendTag(canonical, 2, protowire.Fixed64Type)
+	canonical = protowire.AppendFixed64(canonical, 7)
+	canonical = protowire.AppendTag(canonical, 4, protowire.VarintType)
+	canonical = protowire.AppendVarint(canonical, 1)
+	_, err = decodeSignBytes(protowire.AppendBytes(nil, canonical))
+	assert.ErrorIs(t, err, ErrInvalidSignBytes)
+}
@@ -0,0 +1,98 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+)
+
+// CompactSignatureSize is the size of a r||s secp256k1 signature as used by Cosmos SDK transactions
+const CompactSignatureSize = 64
+
+var (
+	// ErrHighS is returned when a signature is not in the low-S form required by Cosmos SDK
+	ErrHighS = errors.New("signature is not low-S normalized")
+	// ErrSignatureMismatch is returned when a signature from the device does not verify against its public key
+	ErrSignatureMismatch = errors.New("signature does not match the public key and message")
+)
+
+// Signature is a DER encoded secp256k1 signature as returned by the Cosmos app
+type Signature []byte
+
+// DER returns the signature as returned by the device
+func (s Signature) DER() []byte {
+	return []byte(s)
+}
+
+// Compact returns the 64 bytes r||s form used by Cosmos SDK transactions.
+// The device always produces low-S signatures, so high-S output is rejected rather than normalized.
+func (s Signature) Compact() ([]byte, error) {
+	sig, err := ecdsa.ParseDERSignature(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed DER signature from device: %w", err)
+	}
+
+	r, sValue := sig.R(), sig.S()
+	if sValue.IsOverHalfOrder() {
+		return nil, ErrHighS
+	}
+
+	compact := make([]byte, CompactSignatureSize)
+	r.PutBytesUnchecked(compact[:32])
+	sValue.PutBytesUnchecked(compact[32:])
+	return compact, nil
+}
+
+// Base64 returns the compact signature encoded in base64, as found in Cosmos SDK JSON transactions
+func (s Signature) Base64() (string, error) {
+	compact, err := s.Compact()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(compact), nil
+}
+
+// Verify checks the signature over sha256(message) against a compressed or uncompressed secp256k1 public key
+func (s Signature) Verify(pubkey []byte, message []byte) bool {
+	hash := sha256.Sum256(message)
+	return s.verifyHash(pubkey, hash[:])
+}
+
+// VerifyKeccak256 checks the signature over keccak256(message), as produced for coin type 60 paths
+func (s Signature) VerifyKeccak256(pubkey []byte, message []byte) bool {
+	return s.verifyHash(pubkey, keccak256(message))
+}
+
+func (s Signature) verifyHash(pubkey []byte, hash []byte) bool {
+	pub, err := btcec.ParsePubKey(pubkey)
+	if err != nil {
+		return false
+	}
+
+	sig, err := ecdsa.ParseDERSignature(s)
+	if err != nil {
+		return false
+	}
+
+	return sig.Verify(hash, pub)
+}
@@ -0,0 +1,136 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSECP256K1Key derives a deterministic key so that signatures are reproducible
+func testSECP256K1Key() *btcec.PrivateKey {
+	seed := sha256.Sum256([]byte("ledger-cosmos-go test key"))
+	key, _ := btcec.PrivKeyFromBytes(seed[:])
+	return key
+}
+
+// testSign signs sha256(message) like the Cosmos app does
+func testSign(key *btcec.PrivateKey, message []byte) Signature {
+	hash := sha256.Sum256(message)
+	return Signature(ecdsa.Sign(key, hash[:]).Serialize())
+}
+
+func Test_SignatureCompact(t *testing.T) {
+	key := testSECP256K1Key()
+	message := getDummyTx()
+	signature := testSign(key, message)
+
+	compact, err := signature.Compact()
+	require.NoError(t, err)
+	require.Len(t, compact, CompactSignatureSize)
+
+	parsed, err := ecdsa.ParseDERSignature(signature.DER())
+	require.NoError(t, err)
+	r, s := parsed.R(), parsed.S()
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	assert.Equal(t, rBytes[:], compact[:32])
+	assert.Equal(t, sBytes[:], compact[32:])
+
+	b64, err := signature.Base64()
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(compact), b64)
+
+	assert.True(t, signature.Verify(key.PubKey().SerializeCompressed(), message))
+	assert.True(t, signature.Verify(key.PubKey().SerializeUncompressed(), message))
+	assert.False(t, signature.Verify(key.PubKey().SerializeCompressed(), []byte("other")))
+}
+
+func Test_SignatureCompactRejectsHighS(t *testing.T) {
+	signature := testSign(testSECP256K1Key(), getDummyTx())
+
+	parsed, err := ecdsa.ParseDERSignature(signature)
+	require.NoError(t, err)
+	r, s := parsed.R(), parsed.S()
+	s.Negate()
+	// Serialize would normalize S again, so the DER is built by hand
+	highS := Signature(derSignature(r.Bytes(), s.Bytes()))
+
+	_, err = highS.Compact()
+	assert.ErrorIs(t, err, ErrHighS)
+}
+
+func Test_SignatureCompactRejectsMalformed(t *testing.T) {
+	_, err := Signature([]byte{0x30, 0x02, 0x01}).Compact()
+	assert.Error(t, err)
+
+	_, err = Signature(nil).Base64()
+	assert.Error(t, err)
+}
+
+// derSignature encodes r and s as an ASN.1 DER sequence of two integers
+func derSignature(r, s [32]byte) []byte {
+	derInt := func(v []byte) []byte {
+		for len(v) > 1 && v[0] == 0 && v[1]&0x80 == 0 {
+			v = v[1:]
+		}
+		if v[0]&0x80 != 0 {
+			v = append([]byte{0}, v...)
+		}
+		return append([]byte{0x02, byte(len(v))}, v...)
+	}
+	body := append(derInt(r[:]), derInt(s[:])...)
+	return append([]byte{0x30, byte(len(body))}, body...)
+}
+
+func Test_SignAndVerify(t *testing.T) {
+	key := testSECP256K1Key()
+	device := fakeSigningUserApp(key, nil)
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{Major: 2}}
+	path := []uint32{44, 118, 0, 0, 0}
+
+	for i := 0; i < 2; i++ {
+		signature, err := userApp.SignAndVerify(path, getDummyTx(), 0)
+		require.NoError(t, err)
+		assert.True(t, signature.Verify(key.PubKey().SerializeCompressed(), getDummyTx()))
+	}
+
+	// the public key is only requested once per path
+	pubkeyRequests := 0
+	for _, apdu := range device.Sent() {
+		if apdu[1] == userINSGetAddrSecp256k1 {
+			pubkeyRequests++
+		}
+	}
+	assert.Equal(t, 1, pubkeyRequests)
+}
+
+func Test_SignAndVerifyMismatch(t *testing.T) {
+	other := testSECP256K1Key()
+	device := fakeSigningUserApp(testSECP256K1Key(), func(Signature) Signature {
+		return testSign(other, []byte("something else"))
+	})
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{Major: 2}}
+
+	_, err := userApp.SignAndVerify([]uint32{44, 118, 0, 0, 0}, getDummyTx(), 0)
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+}
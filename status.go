@@ -0,0 +1,187 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"errors"
+	"fmt"
+
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+// APDU status words returned by the device
+const (
+	swUserRefused                = 0x5501
+	swDeviceLocked               = 0x5515
+	swExecutionError             = 0x6400
+	swWrongLength                = 0x6700
+	swSecurityStatusNotSatisfied = 0x6982
+	swAuthMethodBlocked          = 0x6983
+	swDataInvalid                = 0x6984
+	swConditionsNotSatisfied     = 0x6985
+	swCommandNotAllowed          = 0x6986
+	swBadKeyHandle               = 0x6A80
+	swInvalidP1P2                = 0x6B00
+	swINSNotSupported            = 0x6D00
+	swCLANotSupported            = 0x6E00
+	swAppNotOpen                 = 0x6E01
+	swUnknown                    = 0x6F00
+	swSignVerifyError            = 0x6F01
+	swOK                         = 0x9000
+	swBusy                       = 0x9001
+)
+
+var (
+	// ErrUserRejected is returned when the user rejects the operation on the device
+	ErrUserRejected = errors.New("operation rejected by the user")
+	// ErrDeviceBusy is returned when the device is still processing a previous request
+	ErrDeviceBusy = errors.New("device is busy")
+	// ErrDeviceLocked is returned when the device is locked and the PIN must be entered
+	ErrDeviceLocked = errors.New("device is locked")
+)
+
+// DeviceError is returned when the device answers with an error status word.
+// It matches ErrUserRejected, ErrDeviceBusy or ErrDeviceLocked with errors.Is when applicable.
+type DeviceError struct {
+	StatusWord uint16
+	Message    string
+	kind       error
+}
+
+func (e *DeviceError) Error() string {
+	return e.Message
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.kind
+}
+
+// knownStatusWords lists the status words that ledger-go turns into a fixed message
+var knownStatusWords = []uint16{
+	swExecutionError,
+	swWrongLength,
+	swSecurityStatusNotSatisfied,
+	swAuthMethodBlocked,
+	swDataInvalid,
+	swConditionsNotSatisfied,
+	swCommandNotAllowed,
+	swBadKeyHandle,
+	swInvalidP1P2,
+	swINSNotSupported,
+	swCLANotSupported,
+	swAppNotOpen,
+	swUnknown,
+	swSignVerifyError,
+}
+
+// statusWordFromError recovers the APDU status word from an error returned by Exchange.
+// ledger-go only exposes the status word through the error message, so it is matched back here.
+func statusWordFromError(err error) (uint16, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		msg := err.Error()
+		for _, sw := range knownStatusWords {
+			if msg == ledger_go.ErrorMessage(sw) {
+				return sw, true
+			}
+		}
+
+		var sw uint16
+		if _, scanErr := fmt.Sscanf(msg, "APDU Error Code from Ledger Device: 0x%04x", &sw); scanErr == nil {
+			return sw, true
+		}
+	}
+	return 0, false
+}
+
+// isAppNotOpen returns true when the device answered but the expected app is not running
+func isAppNotOpen(err error) bool {
+	sw, ok := statusWordFromError(err)
+	return ok && (sw == swCLANotSupported || sw == swAppNotOpen || sw == swINSNotSupported)
+}
+
+// isDeviceLocked returns true when the device refused the command because it is locked
+func isDeviceLocked(err error) bool {
+	sw, ok := statusWordFromError(err)
+	return ok && (sw == swDeviceLocked || sw == swSecurityStatusNotSatisfied)
+}
+
+// localExchangeErrors are the messages of the errors ledger-go returns before sending a badly
+// encoded command. They are bugs of the caller and would fail the same way on any device.
+var localExchangeErrors = []string{
+	"APDU commands should not be smaller than 5",
+	"APDU[data length] mismatch",
+	ledger_go.ErrMsgPacketSize,
+}
+
+// isLocalExchangeError returns true when Exchange rejected the command without sending it
+func isLocalExchangeError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		for _, msg := range localExchangeErrors {
+			if err.Error() == msg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// transportError marks an Exchange that failed without a status word, e.g. because the device is gone
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// isTransportError returns true when err comes from an Exchange that failed without a status word
+func isTransportError(err error) bool {
+	var transportErr *transportError
+	return errors.As(err, &transportErr)
+}
+
+// deviceError turns status word errors from Exchange into a *DeviceError. I/O errors keep their
+// message and are marked as transport errors, commands rejected by ledger-go are returned as is.
+func deviceError(err error) error {
+	sw, ok := statusWordFromError(err)
+	if !ok {
+		if isTransportError(err) || isLocalExchangeError(err) {
+			return err
+		}
+		return &transportError{err}
+	}
+
+	var kind error
+	switch sw {
+	case swUserRefused, swConditionsNotSatisfied, swCommandNotAllowed:
+		kind = ErrUserRejected
+	case swBusy:
+		kind = ErrDeviceBusy
+	case swDeviceLocked, swSecurityStatusNotSatisfied:
+		kind = ErrDeviceLocked
+	}
+
+	return &DeviceError{
+		StatusWord: sw,
+		Message:    err.Error(),
+		kind:       kind,
+	}
+}
@@ -0,0 +1,96 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StatusWordFromError(t *testing.T) {
+	for _, sw := range []uint16{swCLANotSupported, swCommandNotAllowed, swDeviceLocked, swBusy} {
+		found, ok := statusWordFromError(fmt.Errorf("wrapped: %w", apduError(sw)))
+		require.True(t, ok, "status word %04x not recovered", sw)
+		assert.Equal(t, sw, found)
+	}
+
+	_, ok := statusWordFromError(errors.New("hidapi: failed to write"))
+	assert.False(t, ok)
+}
+
+func Test_DeviceErrorKinds(t *testing.T) {
+	cases := []struct {
+		sw   uint16
+		kind error
+	}{
+		{swCommandNotAllowed, ErrUserRejected},
+		{swConditionsNotSatisfied, ErrUserRejected},
+		{swUserRefused, ErrUserRejected},
+		{swBusy, ErrDeviceBusy},
+		{swDeviceLocked, ErrDeviceLocked},
+	}
+
+	for _, c := range cases {
+		err := deviceError(apduError(c.sw))
+		assert.ErrorIs(t, err, c.kind, "status word %04x", c.sw)
+
+		var deviceErr *DeviceError
+		require.ErrorAs(t, err, &deviceErr)
+		assert.Equal(t, c.sw, deviceErr.StatusWord)
+	}
+
+	err := deviceError(apduError(swWrongLength))
+	assert.NotErrorIs(t, err, ErrUserRejected)
+	assert.NotErrorIs(t, err, ErrDeviceBusy)
+
+	assert.False(t, isTransportError(err))
+
+	transportErr := errors.New("hidapi: failed to write")
+	err = deviceError(transportErr)
+	assert.ErrorIs(t, err, transportErr)
+	assert.EqualError(t, err, transportErr.Error())
+	assert.True(t, isTransportError(err))
+
+	// commands ledger-go refuses to send are not transport errors
+	localErr := errors.New("APDU[data length] mismatch")
+	err = deviceError(localErr)
+	assert.Equal(t, localErr, err)
+	assert.False(t, isTransportError(err))
+}
+
+func Test_UserSignRejected(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		if apdu[2] == 2 {
+			return nil, apduError(swCommandNotAllowed)
+		}
+		return nil, nil
+	})
+	userApp := &LedgerCosmos{api: device, version: VersionInfo{Major: 2}}
+
+	_, err := userApp.SignSECP256K1([]uint32{44, 118, 0, 0, 0}, getDummyTx(), 0)
+	assert.ErrorIs(t, err, ErrUserRejected)
+
+	device.handler = func([]byte) ([]byte, error) {
+		return nil, apduError(swBusy)
+	}
+	_, _, err = userApp.GetAddressPubKeySECP256K1([]uint32{44, 118, 0, 0, 0}, "cosmos")
+	assert.ErrorIs(t, err, ErrDeviceBusy)
+}
@@ -0,0 +1,184 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package textual
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// CBOR major types used by the sign doc
+const (
+	majorUint   = 0
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorSimple = 7
+)
+
+const (
+	simpleFalse = 20
+	simpleTrue  = 21
+
+	// maxIndent is the deepest nesting the device can render
+	maxIndent = 255
+)
+
+// encoder writes the subset of CBOR needed by the sign doc, always using the shortest form
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) head(major byte, value uint64) {
+	m := major << 5
+	switch {
+	case value < 24:
+		e.buf = append(e.buf, m|byte(value))
+	case value <= 0xff:
+		e.buf = append(e.buf, m|24, byte(value))
+	case value <= 0xffff:
+		e.buf = append(e.buf, m|25)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(value))
+	case value <= 0xffffffff:
+		e.buf = append(e.buf, m|26)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(value))
+	default:
+		e.buf = append(e.buf, m|27)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, value)
+	}
+}
+
+func (e *encoder) text(s string) {
+	e.head(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.head(majorSimple, simpleTrue)
+	} else {
+		e.head(majorSimple, simpleFalse)
+	}
+}
+
+// decoder reads the subset of CBOR needed by the sign doc and rejects non canonical forms
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of CBOR data")
+
+// head reads an item header and returns its major type and argument
+func (d *decoder) head() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errUnexpectedEnd
+	}
+	offset := d.pos
+	initial := d.data[d.pos]
+	d.pos++
+
+	major, info := initial>>5, initial&0x1f
+	size := 0
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("unsupported additional information %d at byte %d", info, offset)
+	}
+
+	if d.pos+size > len(d.data) {
+		return 0, 0, errUnexpectedEnd
+	}
+	var value uint64
+	for _, b := range d.data[d.pos : d.pos+size] {
+		value = value<<8 | uint64(b)
+	}
+	d.pos += size
+
+	// canonical CBOR uses the shortest possible argument
+	var minimum uint64
+	switch size {
+	case 1:
+		minimum = 24
+	case 2:
+		minimum = 0x100
+	case 4:
+		minimum = 0x10000
+	case 8:
+		minimum = 0x100000000
+	}
+	if value < minimum {
+		return 0, 0, fmt.Errorf("non canonical integer encoding at byte %d", offset)
+	}
+
+	return major, value, nil
+}
+
+func (d *decoder) expectHead(major byte) (uint64, error) {
+	offset := d.pos
+	found, value, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if found != major {
+		return 0, fmt.Errorf("expected CBOR major type %d at byte %d, found %d", major, offset, found)
+	}
+	return value, nil
+}
+
+func (d *decoder) text() (string, error) {
+	length, err := d.expectHead(majorText)
+	if err != nil {
+		return "", err
+	}
+	if length > uint64(len(d.data)-d.pos) {
+		return "", errUnexpectedEnd
+	}
+
+	s := string(d.data[d.pos : d.pos+int(length)])
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("invalid UTF-8 text at byte %d", d.pos)
+	}
+	d.pos += int(length)
+	return s, nil
+}
+
+func (d *decoder) bool() (bool, error) {
+	value, err := d.expectHead(majorSimple)
+	if err != nil {
+		return false, err
+	}
+	switch value {
+	case simpleTrue:
+		return true, nil
+	case simpleFalse:
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected a boolean, found simple value %d", value)
+	}
+}
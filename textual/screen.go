@@ -0,0 +1,218 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+// Package textual encodes SIGN_MODE_TEXTUAL sign docs, the CBOR payload that the
+// Cosmos app expects when SignSECP256K1 is called with P2=1.
+//
+// The sign doc follows the CDDL of the Cosmos SDK (ADR-050):
+//
+//	sign_doc = { screens_key: [* screen] }
+//	screen = {
+//	  ? title_key: tstr,
+//	  ? content_key: tstr,
+//	  ? indent_key: uint,
+//	  ? expert_key: bool,
+//	}
+//	screens_key = 1
+//	title_key = 1
+//	content_key = 2
+//	indent_key = 3
+//	expert_key = 4
+package textual
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	screensKey = 1
+
+	titleKey   = 1
+	contentKey = 2
+	indentKey  = 3
+	expertKey  = 4
+)
+
+// Screen is one page of the transaction as rendered by the device
+type Screen struct {
+	// Title is the label of the screen, it may be empty
+	Title string
+	// Content is the value shown on the screen
+	Content string
+	// Indent is the nesting level of the screen
+	Indent int
+	// Expert screens are only shown when the device is in expert mode
+	Expert bool
+}
+
+// Encode returns the canonical CBOR encoding of screens, ready to be signed with P2=1.
+// Empty titles and contents, zero indents and non expert flags are omitted.
+func Encode(screens []Screen) ([]byte, error) {
+	var e encoder
+	e.head(majorMap, 1)
+	e.head(majorUint, screensKey)
+	e.head(majorArray, uint64(len(screens)))
+
+	for i, screen := range screens {
+		if err := screen.validate(); err != nil {
+			return nil, fmt.Errorf("screen %d: %w", i, err)
+		}
+
+		fields := 0
+		if screen.Title != "" {
+			fields++
+		}
+		if screen.Content != "" {
+			fields++
+		}
+		if screen.Indent > 0 {
+			fields++
+		}
+		if screen.Expert {
+			fields++
+		}
+
+		// keys are small unsigned integers, so numeric order is also the canonical byte order
+		e.head(majorMap, uint64(fields))
+		if screen.Title != "" {
+			e.head(majorUint, titleKey)
+			e.text(screen.Title)
+		}
+		if screen.Content != "" {
+			e.head(majorUint, contentKey)
+			e.text(screen.Content)
+		}
+		if screen.Indent > 0 {
+			e.head(majorUint, indentKey)
+			e.head(majorUint, uint64(screen.Indent))
+		}
+		if screen.Expert {
+			e.head(majorUint, expertKey)
+			e.bool(true)
+		}
+	}
+
+	return e.buf, nil
+}
+
+// Decode parses a textual sign doc. It only accepts the canonical encoding produced by Encode.
+func Decode(bz []byte) ([]Screen, error) {
+	d := decoder{data: bz}
+
+	entries, err := d.expectHead(majorMap)
+	if err != nil {
+		return nil, err
+	}
+	if entries != 1 {
+		return nil, fmt.Errorf("sign doc must have exactly one entry, found %d", entries)
+	}
+	key, err := d.expectHead(majorUint)
+	if err != nil {
+		return nil, err
+	}
+	if key != screensKey {
+		return nil, fmt.Errorf("unknown sign doc key %d", key)
+	}
+
+	count, err := d.expectHead(majorArray)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(len(bz)) {
+		return nil, errors.New("screen count exceeds payload size")
+	}
+
+	screens := make([]Screen, 0, count)
+	for i := uint64(0); i < count; i++ {
+		screen, err := d.screen()
+		if err != nil {
+			return nil, fmt.Errorf("screen %d: %w", i, err)
+		}
+		screens = append(screens, screen)
+	}
+
+	if d.pos != len(bz) {
+		return nil, fmt.Errorf("unexpected data at byte %d", d.pos)
+	}
+	return screens, nil
+}
+
+func (s Screen) validate() error {
+	switch {
+	case s.Indent < 0 || s.Indent > maxIndent:
+		return fmt.Errorf("indent must be between 0 and %d", maxIndent)
+	case !utf8.ValidString(s.Title):
+		return errors.New("title is not valid UTF-8")
+	case !utf8.ValidString(s.Content):
+		return errors.New("content is not valid UTF-8")
+	}
+	return nil
+}
+
+func (d *decoder) screen() (Screen, error) {
+	var screen Screen
+
+	fields, err := d.expectHead(majorMap)
+	if err != nil {
+		return screen, err
+	}
+
+	lastKey := uint64(0)
+	for i := uint64(0); i < fields; i++ {
+		key, err := d.expectHead(majorUint)
+		if err != nil {
+			return screen, err
+		}
+		if key <= lastKey {
+			return screen, fmt.Errorf("key %d is not in canonical order", key)
+		}
+		lastKey = key
+
+		switch key {
+		case titleKey:
+			screen.Title, err = d.text()
+			if err == nil && screen.Title == "" {
+				err = errors.New("empty title must be omitted")
+			}
+		case contentKey:
+			screen.Content, err = d.text()
+			if err == nil && screen.Content == "" {
+				err = errors.New("empty content must be omitted")
+			}
+		case indentKey:
+			var indent uint64
+			indent, err = d.expectHead(majorUint)
+			if err == nil && (indent == 0 || indent > maxIndent) {
+				err = fmt.Errorf("invalid indent %d", indent)
+			}
+			screen.Indent = int(indent)
+		case expertKey:
+			screen.Expert, err = d.bool()
+			if err == nil && !screen.Expert {
+				err = errors.New("false expert flag must be omitted")
+			}
+		default:
+			err = fmt.Errorf("unknown screen key %d", key)
+		}
+		if err != nil {
+			return screen, err
+		}
+	}
+
+	return screen, nil
+}
@@ -0,0 +1,98 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package textual
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EncodeScreens(t *testing.T) {
+	screens := []Screen{
+		{Title: "Chain id", Content: "my-chain"},
+		{Content: "x", Indent: 1, Expert: true},
+		{},
+	}
+
+	bz, err := Encode(screens)
+	require.NoError(t, err)
+
+	expected := "a1" + "01" + "83" +
+		"a2" + "01" + "68" + hex.EncodeToString([]byte("Chain id")) + "02" + "68" + hex.EncodeToString([]byte("my-chain")) +
+		"a3" + "02" + "61" + "78" + "03" + "01" + "04" + "f5" +
+		"a0"
+	assert.Equal(t, expected, hex.EncodeToString(bz))
+
+	decoded, err := Decode(bz)
+	require.NoError(t, err)
+	assert.Equal(t, screens, decoded)
+}
+
+func Test_EncodeLongContentRoundTrip(t *testing.T) {
+	screens := []Screen{
+		{Title: "Memo", Content: strings.Repeat("é", 200), Indent: 30},
+		{Title: "Hash", Content: strings.Repeat("a", 70000)},
+	}
+
+	bz, err := Encode(screens)
+	require.NoError(t, err)
+
+	// 400 bytes of text use a two byte length, 70000 a four byte length
+	assert.Contains(t, hex.EncodeToString(bz), "790190")
+	assert.Contains(t, hex.EncodeToString(bz), "7a00011170")
+
+	decoded, err := Decode(bz)
+	require.NoError(t, err)
+	assert.Equal(t, screens, decoded)
+}
+
+func Test_EncodeInvalidScreens(t *testing.T) {
+	_, err := Encode([]Screen{{Indent: -1}})
+	assert.Error(t, err)
+
+	_, err = Encode([]Screen{{Content: string([]byte{0xff})}})
+	assert.Error(t, err)
+}
+
+func Test_DecodeRejectsNonCanonical(t *testing.T) {
+	cases := map[string]string{
+		"empty":            "",
+		"not a map":        "80",
+		"unknown doc key":  "a10280",
+		"long form length": "a10181a101780161",
+		"unsorted keys":    "a10181a2026178016179",
+		"empty title":      "a10181a10160",
+		"false expert":     "a10181a104f4",
+		"unknown key":      "a10181a10501",
+		"trailing data":    "a1018000",
+		"truncated text":   "a10181a1016561",
+		"indefinite array": "a1019fff",
+	}
+
+	for name, payload := range cases {
+		t.Run(name, func(t *testing.T) {
+			bz, err := hex.DecodeString(payload)
+			require.NoError(t, err)
+			_, err = Decode(bz)
+			assert.Error(t, err)
+		})
+	}
+}
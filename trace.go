@@ -0,0 +1,215 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+// TraceDirection tells whether a traced APDU was sent to or received from the device
+type TraceDirection int
+
+const (
+	// TraceCommand is an APDU sent to the device
+	TraceCommand TraceDirection = iota
+	// TraceResponse is the answer received from the device
+	TraceResponse
+)
+
+func (d TraceDirection) String() string {
+	switch d {
+	case TraceCommand:
+		return "command"
+	case TraceResponse:
+		return "response"
+	default:
+		return fmt.Sprintf("TraceDirection(%d)", int(d))
+	}
+}
+
+// TraceEvent describes one side of an APDU exchange
+type TraceEvent struct {
+	Direction TraceDirection
+	CLA       byte
+	INS       byte
+	P1        byte
+	P2        byte
+	// Length is the number of data bytes sent or received
+	Length int
+	// Payload holds the data bytes. It is nil when the tracer is redacted.
+	Payload []byte
+	// StatusWord is set on responses. It is 0 when the exchange failed at the transport level.
+	StatusWord uint16
+	// Duration is set on responses and measures the whole exchange
+	Duration time.Duration
+	// Err is set on responses when the exchange failed
+	Err error
+}
+
+// Tracer receives every APDU exchanged with the device
+type Tracer interface {
+	TraceAPDU(event TraceEvent)
+}
+
+// TracerFunc adapts a function to the Tracer interface
+type TracerFunc func(event TraceEvent)
+
+func (f TracerFunc) TraceAPDU(event TraceEvent) {
+	f(event)
+}
+
+// RedactTracer returns a tracer that hides payload bytes before passing events to tracer
+func RedactTracer(tracer Tracer) Tracer {
+	return TracerFunc(func(event TraceEvent) {
+		event.Payload = nil
+		tracer.TraceAPDU(event)
+	})
+}
+
+// NewSlogTracer returns a tracer that logs every APDU to logger at the given level
+func NewSlogTracer(logger *slog.Logger, level slog.Level) Tracer {
+	return TracerFunc(func(event TraceEvent) {
+		attrs := []slog.Attr{
+			slog.String("direction", event.Direction.String()),
+			slog.String("cla", fmt.Sprintf("%02x", event.CLA)),
+			slog.String("ins", fmt.Sprintf("%02x", event.INS)),
+			slog.String("p1", fmt.Sprintf("%02x", event.P1)),
+			slog.String("p2", fmt.Sprintf("%02x", event.P2)),
+			slog.Int("length", event.Length),
+		}
+		if event.Payload != nil {
+			attrs = append(attrs, slog.String("payload", hex.EncodeToString(event.Payload)))
+		}
+		if event.Direction == TraceResponse {
+			attrs = append(attrs,
+				slog.String("sw", fmt.Sprintf("%04x", event.StatusWord)),
+				slog.Duration("duration", event.Duration))
+			if event.Err != nil {
+				attrs = append(attrs, slog.String("error", event.Err.Error()))
+			}
+		}
+		logger.LogAttrs(context.Background(), level, "ledger apdu", attrs...)
+	})
+}
+
+// instrumentedDevice reports the APDUs exchanged with the wrapped device
+type instrumentedDevice struct {
+	ledger_go.LedgerDevice
+
+	mu      sync.RWMutex
+	tracer  Tracer
+	metrics Metrics
+}
+
+// instrument wraps device unless it is already instrumented
+func instrument(device ledger_go.LedgerDevice) *instrumentedDevice {
+	if d, ok := device.(*instrumentedDevice); ok {
+		return d
+	}
+	return &instrumentedDevice{LedgerDevice: device}
+}
+
+func (d *instrumentedDevice) setTracer(tracer Tracer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tracer = tracer
+}
+
+func (d *instrumentedDevice) setMetrics(metrics Metrics) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.metrics = metrics
+}
+
+func (d *instrumentedDevice) Exchange(command []byte) ([]byte, error) {
+	d.mu.RLock()
+	tracer, metrics := d.tracer, d.metrics
+	d.mu.RUnlock()
+
+	if (tracer == nil && metrics == nil) || len(command) < 5 {
+		return d.LedgerDevice.Exchange(command)
+	}
+
+	event := TraceEvent{
+		Direction: TraceCommand,
+		CLA:       command[0],
+		INS:       command[1],
+		P1:        command[2],
+		P2:        command[3],
+		Length:    len(command) - 5,
+		Payload:   command[5:],
+	}
+	if tracer != nil {
+		tracer.TraceAPDU(event)
+	}
+
+	start := time.Now()
+	response, err := d.LedgerDevice.Exchange(command)
+	duration := time.Since(start)
+
+	statusWord := uint16(swOK)
+	if err != nil {
+		statusWord, _ = statusWordFromError(err)
+	}
+
+	if tracer != nil {
+		event.Direction = TraceResponse
+		event.Length = len(response)
+		event.Payload = response
+		event.Duration = duration
+		event.Err = err
+		event.StatusWord = statusWord
+		tracer.TraceAPDU(event)
+	}
+
+	if metrics != nil {
+		metrics.ObserveExchange(ExchangeMetric{
+			CLA:        command[0],
+			INS:        command[1],
+			Sent:       len(command) - 5,
+			Received:   len(response),
+			Duration:   duration,
+			StatusWord: statusWord,
+			Err:        err,
+		})
+	}
+
+	return response, err
+}
+
+// SetTracer reports every APDU exchanged with the device to tracer. Pass nil to stop tracing.
+// It should be called before the app is used concurrently.
+func (ledger *LedgerCosmos) SetTracer(tracer Tracer) {
+	device := instrument(ledger.api)
+	device.setTracer(tracer)
+	ledger.api = device
+}
+
+// SetTracer reports every APDU exchanged with the device to tracer. Pass nil to stop tracing.
+// It should be called before the app is used concurrently.
+func (ledger *LedgerTendermintValidator) SetTracer(tracer Tracer) {
+	device := instrument(ledger.api)
+	device.setTracer(tracer)
+	ledger.api = device
+}
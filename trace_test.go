@@ -0,0 +1,98 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TracerUserApp(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		if apdu[1] == userINSGetVersion {
+			return []byte{0, 2, 30, 0}, nil
+		}
+		return nil, apduError(swCommandNotAllowed)
+	})
+	userApp := &LedgerCosmos{api: device}
+
+	var events []TraceEvent
+	userApp.SetTracer(TracerFunc(func(event TraceEvent) {
+		events = append(events, event)
+	}))
+
+	_, err := userApp.GetVersion()
+	require.NoError(t, err)
+	_, _, err = userApp.GetAddressPubKeySECP256K1([]uint32{44, 118, 0, 0, 0}, "cosmos")
+	require.Error(t, err)
+
+	require.Len(t, events, 4)
+
+	assert.Equal(t, TraceCommand, events[0].Direction)
+	assert.Equal(t, byte(userCLA), events[0].CLA)
+	assert.Equal(t, byte(userINSGetVersion), events[0].INS)
+
+	assert.Equal(t, TraceResponse, events[1].Direction)
+	assert.Equal(t, uint16(swOK), events[1].StatusWord)
+	assert.Equal(t, 4, events[1].Length)
+
+	assert.Equal(t, byte(userINSGetAddrSecp256k1), events[2].INS)
+	assert.Equal(t, byte(1), events[2].P1)
+	assert.Equal(t, len(events[2].Payload), events[2].Length)
+
+	assert.Equal(t, uint16(swCommandNotAllowed), events[3].StatusWord)
+	assert.Error(t, events[3].Err)
+
+	// replacing the tracer must not wrap the device twice
+	userApp.SetTracer(nil)
+	_, err = userApp.GetVersion()
+	require.NoError(t, err)
+	assert.Len(t, events, 4)
+	assert.Same(t, device, userApp.api.(*instrumentedDevice).LedgerDevice)
+}
+
+func Test_SlogTracerRedacted(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		return bytes.Repeat([]byte{0xAB}, 32), nil
+	})
+	validatorApp := &LedgerTendermintValidator{api: device}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	validatorApp.SetTracer(RedactTracer(NewSlogTracer(logger, slog.LevelInfo)))
+
+	_, err := validatorApp.GetPublicKeyED25519([]uint32{44, 118, 0, 0, 0})
+	require.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(lines[1], &response))
+	assert.Equal(t, "response", response["direction"])
+	assert.Equal(t, "56", response["cla"])
+	assert.Equal(t, "01", response["ins"])
+	assert.Equal(t, "9000", response["sw"])
+	assert.EqualValues(t, 32, response["length"])
+	assert.NotContains(t, response, "payload")
+	assert.NotContains(t, buf.String(), "abab")
+}
@@ -0,0 +1,179 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SignMode is the sign mode recorded in the signer info of a protobuf transaction
+type SignMode int32
+
+const (
+	SignModeTextual         SignMode = 5
+	SignModeLegacyAminoJSON SignMode = 127
+)
+
+// SignModeFromP2 returns the sign mode matching the P2 value passed to SignSECP256K1
+func SignModeFromP2(p2 byte) (SignMode, error) {
+	switch p2 {
+	case 0:
+		return SignModeLegacyAminoJSON, nil
+	case 1:
+		return SignModeTextual, nil
+	default:
+		return 0, fmt.Errorf("unsupported sign mode P2=%d", p2)
+	}
+}
+
+// stdTx is the legacy amino transaction, fields are in the order used by the Cosmos SDK
+type stdTx struct {
+	Msgs          []json.RawMessage `json:"msg"`
+	Fee           StdFee            `json:"fee"`
+	Signatures    []stdSignature    `json:"signatures"`
+	Memo          string            `json:"memo"`
+	TimeoutHeight uint64            `json:"timeout_height,string"`
+}
+
+type stdSignature struct {
+	PubKey    json.RawMessage `json:"pub_key"`
+	Signature []byte          `json:"signature"`
+}
+
+// AssembleStdTx returns the amino JSON of the transaction signed in SIGN_MODE_LEGACY_AMINO_JSON,
+// {"type":"cosmos-sdk/StdTx","value":{...}}, from the sign doc, the compressed public key from
+// GetPublicKeySECP256K1 and the signature from SignSECP256K1.
+func AssembleStdTx(doc StdSignDoc, pubkey []byte, signature Signature) ([]byte, error) {
+	pubkeyJSON, err := Secp256k1PubKey(pubkey).AminoJSON()
+	if err != nil {
+		return nil, err
+	}
+	compact, err := signature.Compact()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := stdTx{
+		Msgs:          doc.Msgs,
+		Fee:           doc.Fee,
+		Signatures:    []stdSignature{{PubKey: pubkeyJSON, Signature: compact}},
+		Memo:          doc.Memo,
+		TimeoutHeight: doc.TimeoutHeight,
+	}
+	if tx.Msgs == nil {
+		tx.Msgs = []json.RawMessage{}
+	}
+	if tx.Fee.Amount == nil {
+		tx.Fee.Amount = []Coin{}
+	}
+
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Value stdTx  `json:"value"`
+	}{"cosmos-sdk/StdTx", tx})
+}
+
+// ProtoSigner describes the single signer of a protobuf transaction
+type ProtoSigner struct {
+	// PubKey is the compressed public key from GetPublicKeySECP256K1
+	PubKey   []byte
+	Sequence uint64
+	SignMode SignMode
+}
+
+// AuthInfoBytes returns the encoded cosmos.tx.v1beta1.AuthInfo of a transaction with one signer.
+// With SIGN_MODE_TEXTUAL these bytes are part of what the device displays, so the same bytes must
+// be used to render the sign doc and to assemble the transaction.
+func AuthInfoBytes(signer ProtoSigner, fee StdFee) ([]byte, error) {
+	pubkeyAny, err := Secp256k1PubKey(signer.PubKey).MarshalAny()
+	if err != nil {
+		return nil, err
+	}
+
+	// ModeInfo{single: {mode}}
+	var single []byte
+	single = appendVarintField(single, 1, uint64(signer.SignMode))
+	var modeInfo []byte
+	modeInfo = appendMessageField(modeInfo, 1, single)
+
+	var signerInfo []byte
+	signerInfo = appendBytesField(signerInfo, 1, pubkeyAny)
+	signerInfo = appendMessageField(signerInfo, 2, modeInfo)
+	signerInfo = appendVarintField(signerInfo, 3, signer.Sequence)
+
+	var feeBytes []byte
+	for _, coin := range fee.Amount {
+		var c []byte
+		c = appendStringField(c, 1, coin.Denom)
+		c = appendStringField(c, 2, coin.Amount)
+		feeBytes = appendMessageField(feeBytes, 1, c)
+	}
+	feeBytes = appendVarintField(feeBytes, 2, fee.Gas)
+	feeBytes = appendStringField(feeBytes, 3, fee.Payer)
+	feeBytes = appendStringField(feeBytes, 4, fee.Granter)
+
+	var authInfo []byte
+	authInfo = appendMessageField(authInfo, 1, signerInfo)
+	authInfo = appendMessageField(authInfo, 2, feeBytes)
+	return authInfo, nil
+}
+
+// AssembleTxRaw returns the encoded cosmos.tx.v1beta1.TxRaw, ready to broadcast, from the
+// encoded TxBody, the AuthInfo from AuthInfoBytes and the signature from SignSECP256K1
+func AssembleTxRaw(bodyBytes []byte, authInfoBytes []byte, signature Signature) ([]byte, error) {
+	compact, err := signature.Compact()
+	if err != nil {
+		return nil, err
+	}
+
+	var txRaw []byte
+	txRaw = appendBytesField(txRaw, 1, bodyBytes)
+	txRaw = appendBytesField(txRaw, 2, authInfoBytes)
+	txRaw = appendMessageField(txRaw, 3, compact)
+	return txRaw, nil
+}
+
+// appendBytesField appends a length delimited field, omitted when empty like proto3 does
+func appendBytesField(b []byte, num protowire.Number, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func appendStringField(b []byte, num protowire.Number, value string) []byte {
+	return appendBytesField(b, num, []byte(value))
+}
+
+// appendVarintField appends a varint field, omitted when zero like proto3 does
+func appendVarintField(b []byte, num protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+// appendMessageField appends an embedded message, which is present even when empty
+func appendMessageField(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
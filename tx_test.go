@@ -0,0 +1,123 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AssembleStdTx(t *testing.T) {
+	key := testSECP256K1Key()
+	pubkey := key.PubKey().SerializeCompressed()
+	doc := StdSignDoc{
+		ChainID: "cosmoshub-4",
+		Fee:     StdFee{Amount: []Coin{{Amount: "5000", Denom: "uatom"}}, Gas: 200000},
+		Memo:    "memo",
+		Msgs:    []json.RawMessage{json.RawMessage(`{"type":"cosmos-sdk/MsgSend","value":{}}`)},
+	}
+	signBytes, err := doc.Marshal()
+	require.NoError(t, err)
+	signature := testSign(key, signBytes)
+	compact, err := signature.Compact()
+	require.NoError(t, err)
+
+	bz, err := AssembleStdTx(doc, pubkey, signature)
+	require.NoError(t, err)
+
+	expected := `{"type":"cosmos-sdk/StdTx","value":{"msg":[{"type":"cosmos-sdk/MsgSend","value":{}}],` +
+		`"fee":{"amount":[{"amount":"5000","denom":"uatom"}],"gas":"200000"},"signatures":[{"pub_key":` +
+		`{"type":"tendermint/PubKeySecp256k1","value":"` + base64.StdEncoding.EncodeToString(pubkey) + `"},` +
+		`"signature":"` + base64.StdEncoding.EncodeToString(compact) + `"}],"memo":"memo","timeout_height":"0"}}`
+	assert.Equal(t, expected, string(bz))
+
+	_, err = AssembleStdTx(doc, key.PubKey().SerializeUncompressed(), signature)
+	assert.Error(t, err)
+}
+
+func Test_AuthInfoBytes(t *testing.T) {
+	pubkey := testSECP256K1Key().PubKey().SerializeCompressed()
+	fee := StdFee{Amount: []Coin{{Amount: "5000", Denom: "uatom"}}, Gas: 200000}
+
+	authInfo, err := AuthInfoBytes(ProtoSigner{PubKey: pubkey, Sequence: 3, SignMode: SignModeTextual}, fee)
+	require.NoError(t, err)
+
+	typeURL := "/cosmos.crypto.secp256k1.PubKey"
+	pubkeyAny := append([]byte{0x0a, byte(len(typeURL))}, typeURL...)
+	pubkeyAny = append(pubkeyAny, 0x12, 35, 0x0a, 33)
+	pubkeyAny = append(pubkeyAny, pubkey...)
+
+	signerInfo := append([]byte{0x0a, byte(len(pubkeyAny))}, pubkeyAny...)
+	signerInfo = append(signerInfo, 0x12, 4, 0x0a, 2, 0x08, 5, 0x18, 3)
+
+	coin := []byte{0x0a, 5, 'u', 'a', 't', 'o', 'm', 0x12, 4, '5', '0', '0', '0'}
+	feeBytes := append([]byte{0x0a, byte(len(coin))}, coin...)
+	feeBytes = append(feeBytes, 0x10, 0xc0, 0x9a, 0x0c)
+
+	expected := append([]byte{0x0a, byte(len(signerInfo))}, signerInfo...)
+	expected = append(expected, 0x12, byte(len(feeBytes)))
+	expected = append(expected, feeBytes...)
+	assert.Equal(t, expected, authInfo)
+
+	amino, err := AuthInfoBytes(ProtoSigner{PubKey: pubkey, SignMode: SignModeLegacyAminoJSON}, fee)
+	require.NoError(t, err)
+	assert.Contains(t, string(amino), string([]byte{0x12, 4, 0x0a, 2, 0x08, 0x7f}))
+
+	_, err = AuthInfoBytes(ProtoSigner{PubKey: pubkey[:32]}, fee)
+	assert.Error(t, err)
+}
+
+func Test_AssembleTxRaw(t *testing.T) {
+	key := testSECP256K1Key()
+	body := []byte{0x0a, 0x01, 0x00}
+	authInfo, err := AuthInfoBytes(ProtoSigner{PubKey: key.PubKey().SerializeCompressed(), SignMode: SignModeTextual}, StdFee{Gas: 1})
+	require.NoError(t, err)
+
+	signature := testSign(key, []byte("textual sign doc"))
+	compact, err := signature.Compact()
+	require.NoError(t, err)
+
+	txRaw, err := AssembleTxRaw(body, authInfo, signature)
+	require.NoError(t, err)
+
+	expected := append([]byte{0x0a, 3}, body...)
+	expected = append(expected, 0x12, byte(len(authInfo)))
+	expected = append(expected, authInfo...)
+	expected = append(expected, 0x1a, 64)
+	expected = append(expected, compact...)
+	assert.Equal(t, expected, txRaw)
+
+	_, err = AssembleTxRaw(body, authInfo, Signature{1, 2, 3})
+	assert.Error(t, err)
+}
+
+func Test_SignModeFromP2(t *testing.T) {
+	mode, err := SignModeFromP2(0)
+	require.NoError(t, err)
+	assert.Equal(t, SignModeLegacyAminoJSON, mode)
+
+	mode, err = SignModeFromP2(1)
+	require.NoError(t, err)
+	assert.Equal(t, SignModeTextual, mode)
+
+	_, err = SignModeFromP2(2)
+	assert.Error(t, err)
+}
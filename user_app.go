@@ -17,8 +17,12 @@
 package ledger_cosmos_go
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	ledger_go "github.com/zondax/ledger-go"
 )
@@ -29,17 +33,27 @@ const (
 	userINSGetVersion       = 0
 	userINSSignSECP256K1    = 2
 	userINSGetAddrSecp256k1 = 4
+
+	userMessageChunkSize = ledger_go.DefaultChunkSize
 )
 
 // LedgerCosmos represents a connection to the Cosmos app in a Ledger Nano S device
 type LedgerCosmos struct {
-	api     ledger_go.LedgerDevice
-	version VersionInfo
+	api      ledger_go.LedgerDevice
+	version  VersionInfo
+	targetID uint32
+
+	// progress, if set, is called after every chunk of a signing request
+	progress func(ChunkProgress)
+
+	// pubkeys caches public keys used to verify signatures, indexed by path
+	pubkeysMu sync.Mutex
+	pubkeys   map[string][]byte
 }
 
 // FindLedgerCosmosUserApp finds a Cosmos user app running in a ledger device
 func FindLedgerCosmosUserApp() (_ *LedgerCosmos, rerr error) {
-	ledgerAdmin := ledger_go.NewLedgerAdmin()
+	ledgerAdmin := newLedgerAdmin()
 	ledgerAPI, err := ledgerAdmin.Connect(0)
 	if err != nil {
 		return nil, err
@@ -51,10 +65,10 @@ func FindLedgerCosmosUserApp() (_ *LedgerCosmos, rerr error) {
 		}
 	}()
 
-	app := &LedgerCosmos{ledgerAPI, VersionInfo{}}
+	app := &LedgerCosmos{api: ledgerAPI}
 	appVersion, err := app.GetVersion()
 	if err != nil {
-		if err.Error() == "[APDU_CODE_CLA_NOT_SUPPORTED] Class not supported" {
+		if isAppNotOpen(err) {
 			err = errors.New("are you sure the Cosmos app is open?")
 		}
 		return nil, err
@@ -94,7 +108,7 @@ func (ledger *LedgerCosmos) GetVersion() (*VersionInfo, error) {
 	message := []byte{userCLA, userINSGetVersion, 0, 0, 0}
 	response, err := ledger.api.Exchange(message)
 	if err != nil {
-		return nil, err
+		return nil, deviceError(err)
 	}
 
 	if len(response) < 4 {
@@ -108,23 +122,103 @@ func (ledger *LedgerCosmos) GetVersion() (*VersionInfo, error) {
 		Patch:   response[3],
 	}
 
+	// newer apps also report the lock status and the device target id
+	if len(response) >= 9 {
+		ledger.targetID = binary.BigEndian.Uint32(response[5:9])
+	}
+
 	return &ledger.version, nil
 }
 
+// DeviceModel returns the device model reported by the app, as of the last GetVersion call
+func (ledger *LedgerCosmos) DeviceModel() DeviceModel {
+	return deviceModelFromTargetID(ledger.targetID)
+}
+
 // SignSECP256K1 signs a transaction using Cosmos user app. It can either use
 // SIGN_MODE_LEGACY_AMINO_JSON (P2=0) or SIGN_MODE_TEXTUAL (P2=1).
+// The returned signature is DER encoded, use Signature.Compact to get the Cosmos SDK format.
+// Amino JSON transactions are checked with ValidateAminoJSON before anything is sent.
+// Coin type 60 paths are signed over keccak256 instead of sha256 and need EthereumMinVersion.
 // this command requires user confirmation in the device
-func (ledger *LedgerCosmos) SignSECP256K1(bip32Path []uint32, transaction []byte, p2 byte) ([]byte, error) {
+func (ledger *LedgerCosmos) SignSECP256K1(bip32Path []uint32, transaction []byte, p2 byte) (Signature, error) {
+	return ledger.SignSECP256K1Context(context.Background(), bip32Path, transaction, p2)
+}
+
+// SignSECP256K1Context is SignSECP256K1 stopping between chunks once ctx is done
+func (ledger *LedgerCosmos) SignSECP256K1Context(ctx context.Context, bip32Path []uint32, transaction []byte, p2 byte) (signature Signature, err error) {
+	var confirmation time.Duration
+	defer observeSign(ledger.api, "SignSECP256K1", time.Now(), &confirmation, &err)
+
+	if err := ledger.checkCoinType(bip32Path); err != nil {
+		return nil, err
+	}
+
+	if p2 == 0 {
+		if err := ValidateAminoJSON(transaction, ledger.DeviceModel()); err != nil {
+			return nil, err
+		}
+	}
+
 	switch major := ledger.version.Major; major {
 	case 1:
-		return ledger.signv1(bip32Path, transaction)
+		return ledger.signv1(ctx, bip32Path, transaction, &confirmation)
 	case 2:
-		return ledger.signv2(bip32Path, transaction, p2)
+		return ledger.signv2(ctx, bip32Path, transaction, p2, &confirmation)
 	default:
 		return nil, fmt.Errorf("App version %d is not supported", major)
 	}
 }
 
+// SignAndVerify signs a transaction like SignSECP256K1 and verifies the signature against the
+// public key of bip32Path before returning it. The public key is fetched once per path and cached.
+// ErrSignatureMismatch is returned if the device produced an invalid signature.
+// this command requires user confirmation in the device
+func (ledger *LedgerCosmos) SignAndVerify(bip32Path []uint32, transaction []byte, p2 byte) (Signature, error) {
+	pubkey, err := ledger.cachedPublicKeySECP256K1(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ledger.SignSECP256K1(bip32Path, transaction, p2)
+	if err != nil {
+		return nil, err
+	}
+
+	verify := signature.Verify
+	if coinType(bip32Path) == CoinTypeEthereum {
+		verify = signature.VerifyKeccak256
+	}
+	if !verify(pubkey, transaction) {
+		return nil, ErrSignatureMismatch
+	}
+	return signature, nil
+}
+
+func (ledger *LedgerCosmos) cachedPublicKeySECP256K1(bip32Path []uint32) ([]byte, error) {
+	key := fmt.Sprint(bip32Path)
+
+	ledger.pubkeysMu.Lock()
+	pubkey, ok := ledger.pubkeys[key]
+	ledger.pubkeysMu.Unlock()
+	if ok {
+		return pubkey, nil
+	}
+
+	pubkey, err := ledger.GetPublicKeySECP256K1(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger.pubkeysMu.Lock()
+	defer ledger.pubkeysMu.Unlock()
+	if ledger.pubkeys == nil {
+		ledger.pubkeys = make(map[string][]byte)
+	}
+	ledger.pubkeys[key] = pubkey
+	return pubkey, nil
+}
+
 // GetPublicKeySECP256K1 retrieves the public key for the corresponding bip32 derivation path (compressed)
 // this command DOES NOT require user confirmation in the device
 func (ledger *LedgerCosmos) GetPublicKeySECP256K1(bip32Path []uint32) ([]byte, error) {
@@ -184,60 +278,52 @@ func cosmosErrorHandler(err error, response []byte, instruction byte) error {
 		errorMsg := string(response)
 		return errors.New(errorMsg)
 	}
-	return err
+	return deviceError(err)
 }
 
-func (ledger *LedgerCosmos) signv1(bip32Path []uint32, transaction []byte) ([]byte, error) {
-	// Get path bytes
+// signv1 sends the path and transaction with the packet index and count in P1 and P2
+func (ledger *LedgerCosmos) signv1(ctx context.Context, bip32Path []uint32, transaction []byte, confirmation *time.Duration) ([]byte, error) {
 	pathBytes, err := ledger.GetBip32bytes(bip32Path, 3)
 	if err != nil {
 		return nil, err
 	}
 
-	// Prepare chunks using ledger-go chunking
-	chunks := ledger_go.PrepareChunks(pathBytes, transaction)
-
-	// For v1, we need to handle the packet indexing differently
-	// v1 uses 1-based packet indexing in P1 and packet count in P2
-	packetCount := byte(len(chunks))
-	var finalResponse []byte
-
-	for packetIndex, chunk := range chunks {
-		// v1 uses 1-based indexing
-		p1 := byte(packetIndex + 1)
-		p2 := packetCount
-		payloadLen := byte(len(chunk))
-
-		header := []byte{userCLA, userINSSignSECP256K1, p1, p2, payloadLen}
-		message := append(header, chunk...)
-
-		response, err := ledger.api.Exchange(message)
-		if err != nil {
-			return nil, cosmosErrorHandler(err, response, userINSSignSECP256K1)
-		}
-
-		finalResponse = response
+	cmd := chunkedCommand{
+		cla:          userCLA,
+		ins:          userINSSignSECP256K1,
+		framing:      framingPacketIndex,
+		chunkSize:    userMessageChunkSize,
+		errorHandler: userSignErrorHandler,
+		confirmation: confirmation,
 	}
-
-	return finalResponse, nil
+	return exchangeChunks(ctx, ledger.api, cmd, pathBytes, transaction, ledger.progress)
 }
 
-func (ledger *LedgerCosmos) signv2(bip32Path []uint32, transaction []byte, p2 byte) ([]byte, error) {
+// signv2 sends the path and transaction with init/add/last in P1 and the sign mode in P2
+func (ledger *LedgerCosmos) signv2(ctx context.Context, bip32Path []uint32, transaction []byte, p2 byte, confirmation *time.Duration) ([]byte, error) {
 	if p2 > 1 {
 		return nil, errors.New("only values of SIGN_MODE_LEGACY_AMINO (P2=0) and SIGN_MODE_TEXTUAL (P2=1) are allowed")
 	}
 
-	// Get path bytes
 	pathBytes, err := ledger.GetBip32bytes(bip32Path, 3)
 	if err != nil {
 		return nil, err
 	}
 
-	// Prepare chunks using ledger-go chunking
-	chunks := ledger_go.PrepareChunks(pathBytes, transaction)
+	cmd := chunkedCommand{
+		cla:          userCLA,
+		ins:          userINSSignSECP256K1,
+		framing:      framingInitAddLast,
+		p2:           p2,
+		chunkSize:    userMessageChunkSize,
+		errorHandler: userSignErrorHandler,
+		confirmation: confirmation,
+	}
+	return exchangeChunks(ctx, ledger.api, cmd, pathBytes, transaction, ledger.progress)
+}
 
-	// Use ProcessChunks with custom error handler
-	return ledger_go.ProcessChunks(ledger.api, chunks, userCLA, userINSSignSECP256K1, p2, cosmosErrorHandler)
+func userSignErrorHandler(err error, response []byte) error {
+	return cosmosErrorHandler(err, response, userINSSignSECP256K1)
 }
 
 // GetAddressPubKeySECP256K1 returns the pubkey (compressed) and address (bech(
@@ -254,6 +340,10 @@ func (ledger *LedgerCosmos) getAddressPubKeySECP256K1(bip32Path []uint32, hrp st
 		}
 	}
 
+	if err := ledger.checkCoinType(bip32Path); err != nil {
+		return nil, "", err
+	}
+
 	pathBytes, err := ledger.GetBip32bytes(bip32Path, 3)
 	if err != nil {
 		return nil, "", err
@@ -273,7 +363,7 @@ func (ledger *LedgerCosmos) getAddressPubKeySECP256K1(bip32Path []uint32, hrp st
 
 	response, err := ledger.api.Exchange(message)
 	if err != nil {
-		return nil, "", err
+		return nil, "", deviceError(err)
 	}
 	if len(response) < 35+len(hrp) {
 		return nil, "", errors.New("Invalid response")
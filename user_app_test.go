@@ -241,6 +241,11 @@ func Test_UserSign(t *testing.T) {
 		t.Fatalf("[VerifySig] Error verifying signature: %s", err.Error())
 		return
 	}
+
+	assert.True(t, signature.Verify(pubKey, message))
+	compact, err := signature.Compact()
+	require.NoError(t, err)
+	assert.Len(t, compact, CompactSignatureSize)
 }
 
 func Test_UserSign_Fails(t *testing.T) {
@@ -257,10 +262,9 @@ func Test_UserSign_Fails(t *testing.T) {
 	message = append(garbage, message...)
 
 	_, err = userApp.SignSECP256K1(path, message, 0)
-	assert.Error(t, err)
-	errMessage := err.Error()
 
-	if errMessage != "Invalid character in JSON string" && errMessage != "Unexpected characters" {
-		assert.Fail(t, "Unexpected error message returned: "+errMessage)
-	}
+	// the payload is rejected locally, before reaching the device
+	var validationErr *AminoValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, 0, validationErr.Offset)
 }
@@ -17,8 +17,12 @@
 package ledger_cosmos_go
 
 import (
+	"context"
+	"crypto/ed25519"
 	"errors"
-	"math"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/zondax/ledger-go"
 )
@@ -30,23 +34,57 @@ const (
 	validatorINSPublicKeyED25519 = 1
 	validatorINSSignED25519      = 2
 
-	validatorMessageChunkSize = 250
+	validatorMessageChunkSize        = 250
+	validatorChunkedMessageChunkSize = ledger_go.DefaultChunkSize
+)
+
+var (
+	// ErrSignRefused is returned when the validator app refuses to sign a vote or proposal that
+	// does not advance its last signed height, round and step
+	ErrSignRefused = errors.New("validator app refused to sign: height/round/step regression")
+	// ErrInvalidSignBytes is returned when the validator app cannot parse the message to sign
+	ErrInvalidSignBytes = errors.New("validator app could not parse the message to sign")
+	// ErrMessageTooLarge is returned when a message does not fit in the 255 packets of the legacy sign command
+	ErrMessageTooLarge = errors.New("message is too large for the app")
 )
 
 // Validator app
 type LedgerTendermintValidator struct {
 	// Add support for this app
 	api ledger_go.LedgerDevice
+
+	// deviceMu is held for every command sequence, e.g. all the chunks of a signature,
+	// so that the APDUs of concurrent calls never interleave
+	deviceMu sync.Mutex
+
+	// progress, if set, is called after every chunk of a signing request
+	progress func(ChunkProgress)
+
+	// version is the app version of the last GetVersion call, it selects the chunking scheme.
+	// It is fetched by the first signature when unset.
+	versionMu sync.Mutex
+	version   VersionInfo
+
+	// pubkeys caches public keys used to verify signatures, indexed by path
+	pubkeysMu sync.Mutex
+	pubkeys   map[string]ed25519.PublicKey
 }
 
-// RequiredCosmosUserAppVersion indicates the minimum required version of the Tendermint app
+// RequiredTendermintValidatorAppVersion indicates the minimum required version of the Tendermint app.
+// Any newer app is supported: apps before 1.0.0 sign with the legacy packet index/count chunking,
+// newer ones with init/add/last.
 func RequiredTendermintValidatorAppVersion() VersionInfo {
 	return VersionInfo{0, 0, 5, 0}
 }
 
+// CheckVersion returns an error if the app version is older than RequiredTendermintValidatorAppVersion
+func (ledger *LedgerTendermintValidator) CheckVersion(ver VersionInfo) error {
+	return CheckVersion(ver, RequiredTendermintValidatorAppVersion())
+}
+
 // FindLedgerCosmosValidatorApp finds a Cosmos validator app running in a ledger device
 func FindLedgerTendermintValidatorApp() (_ *LedgerTendermintValidator, rerr error) {
-	ledgerAdmin := ledger_go.NewLedgerAdmin()
+	ledgerAdmin := newLedgerAdmin()
 	ledgerAPI, err := ledgerAdmin.Connect(0)
 	if err != nil {
 		return nil, err
@@ -58,17 +96,16 @@ func FindLedgerTendermintValidatorApp() (_ *LedgerTendermintValidator, rerr erro
 		}
 	}()
 
-	ledgerCosmosValidatorApp := &LedgerTendermintValidator{ledgerAPI}
+	ledgerCosmosValidatorApp := &LedgerTendermintValidator{api: ledgerAPI}
 	appVersion, err := ledgerCosmosValidatorApp.GetVersion()
 	if err != nil {
-		if err.Error() == "[APDU_CODE_CLA_NOT_SUPPORTED] Class not supported" {
+		if isAppNotOpen(err) {
 			err = errors.New("are you sure the Tendermint Validator app is open?")
 		}
 		return nil, err
 	}
 
-	req := RequiredTendermintValidatorAppVersion()
-	if err := CheckVersion(*appVersion, req); err != nil {
+	if err := ledgerCosmosValidatorApp.CheckVersion(*appVersion); err != nil {
 		return nil, err
 	}
 
@@ -82,26 +119,44 @@ func (ledger *LedgerTendermintValidator) Close() error {
 
 // GetVersion returns the current version of the Cosmos user app
 func (ledger *LedgerTendermintValidator) GetVersion() (*VersionInfo, error) {
+	ledger.deviceMu.Lock()
+	defer ledger.deviceMu.Unlock()
+	return ledger.getVersion()
+}
+
+func (ledger *LedgerTendermintValidator) getVersion() (*VersionInfo, error) {
 	message := []byte{validatorCLA, validatorINSGetVersion, 0, 0, 0}
 	response, err := ledger.api.Exchange(message)
 	if err != nil {
-		return nil, err
+		return nil, deviceError(err)
 	}
 
 	if len(response) < 4 {
 		return nil, errors.New("invalid response")
 	}
 
-	return &VersionInfo{
+	version := VersionInfo{
 		AppMode: response[0],
 		Major:   response[1],
 		Minor:   response[2],
 		Patch:   response[3],
-	}, nil
+	}
+
+	ledger.versionMu.Lock()
+	ledger.version = version
+	ledger.versionMu.Unlock()
+
+	return &version, nil
 }
 
 // GetPublicKeyED25519 retrieves the public key for the corresponding bip32 derivation path
 func (ledger *LedgerTendermintValidator) GetPublicKeyED25519(bip32Path []uint32) ([]byte, error) {
+	ledger.deviceMu.Lock()
+	defer ledger.deviceMu.Unlock()
+	return ledger.getPublicKeyED25519(bip32Path)
+}
+
+func (ledger *LedgerTendermintValidator) getPublicKeyED25519(bip32Path []uint32) ([]byte, error) {
 	pathBytes, err := GetBip32bytesv1(bip32Path, 10)
 	if err != nil {
 		return nil, err
@@ -112,7 +167,7 @@ func (ledger *LedgerTendermintValidator) GetPublicKeyED25519(bip32Path []uint32)
 
 	response, err := ledger.api.Exchange(message)
 	if err != nil {
-		return nil, err
+		return nil, deviceError(err)
 	}
 
 	if len(response) < 4 {
@@ -122,57 +177,116 @@ func (ledger *LedgerTendermintValidator) GetPublicKeyED25519(bip32Path []uint32)
 	return response, nil
 }
 
-// SignSECP256K1 signs a message/vote using the Tendermint validator app
+// SignED25519 signs a message/vote using the Tendermint validator app.
+// The signature is checked to be a valid ed25519 signature of message by the key of bip32Path,
+// the public key is fetched once per path and cached.
+// ErrSignRefused is returned when the app refuses to sign a height/round/step regression.
 func (ledger *LedgerTendermintValidator) SignED25519(bip32Path []uint32, message []byte) ([]byte, error) {
-	var packetIndex byte = 1
-	packetCount := 1 + byte(math.Ceil(float64(len(message))/float64(validatorMessageChunkSize)))
-
-	var finalResponse []byte
-
-	var apduMessage []byte
-
-	for packetIndex <= packetCount {
-		chunk := validatorMessageChunkSize
-		if packetIndex == 1 {
-			pathBytes, err := GetBip32bytesv1(bip32Path, 10)
-			if err != nil {
-				return nil, err
-			}
-			header := []byte{
-				validatorCLA,
-				validatorINSSignED25519,
-				packetIndex,
-				packetCount,
-				byte(len(pathBytes)),
-			}
-
-			apduMessage = append(header, pathBytes...)
-		} else {
-			if len(message) < validatorMessageChunkSize {
-				chunk = len(message)
-			}
-			header := []byte{
-				validatorCLA,
-				validatorINSSignED25519,
-				packetIndex,
-				packetCount,
-				byte(chunk),
-			}
-
-			apduMessage = append(header, message[:chunk]...)
-		}
+	return ledger.SignED25519Context(context.Background(), bip32Path, message)
+}
+
+// SignED25519Context is SignED25519 stopping between chunks once ctx is done
+func (ledger *LedgerTendermintValidator) SignED25519Context(ctx context.Context, bip32Path []uint32, message []byte) (signature []byte, err error) {
+	var confirmation time.Duration
+	defer observeSign(ledger.api, "SignED25519", time.Now(), &confirmation, &err)
+
+	pathBytes, err := GetBip32bytesv1(bip32Path, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger.deviceMu.Lock()
+	defer ledger.deviceMu.Unlock()
 
-		response, err := ledger.api.Exchange(apduMessage)
+	ledger.versionMu.Lock()
+	version := ledger.version
+	ledger.versionMu.Unlock()
+
+	if version == (VersionInfo{}) {
+		fetched, err := ledger.getVersion()
 		if err != nil {
 			return nil, err
 		}
+		version = *fetched
+	}
 
-		finalResponse = response
-		if packetIndex > 1 {
-			message = message[chunk:]
-		}
-		packetIndex++
+	// The 0.x releases, up to the 0.9.0 checked by Test_ValGetVersion, take the packet index
+	// and count in P1 and P2. From 1.0.0 the app takes init/add/last in P1 like the Cosmos user
+	// app from 2.0.0 (signv2). Both send the path of GetBip32bytesv1 as the first chunk.
+	cmd := chunkedCommand{
+		cla:          validatorCLA,
+		ins:          validatorINSSignED25519,
+		framing:      framingInitAddLast,
+		chunkSize:    validatorChunkedMessageChunkSize,
+		errorHandler: func(err error, _ []byte) error { return validatorError(err) },
+		confirmation: &confirmation,
+	}
+	if version.Major == 0 {
+		cmd.framing, cmd.chunkSize = framingPacketIndex, validatorMessageChunkSize
+	}
+
+	response, err := exchangeChunks(ctx, ledger.api, cmd, pathBytes, message, ledger.progress)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature length %d, expected %d", len(response), ed25519.SignatureSize)
+	}
 
+	pubkey, err := ledger.cachedPublicKeyED25519(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(pubkey, message, response) {
+		return nil, ErrSignatureMismatch
+	}
+
+	return response, nil
+}
+
+// cachedPublicKeyED25519 must be called with deviceMu held
+func (ledger *LedgerTendermintValidator) cachedPublicKeyED25519(bip32Path []uint32) (ed25519.PublicKey, error) {
+	key := fmt.Sprint(bip32Path)
+
+	ledger.pubkeysMu.Lock()
+	pubkey, ok := ledger.pubkeys[key]
+	ledger.pubkeysMu.Unlock()
+	if ok {
+		return pubkey, nil
+	}
+
+	bz, err := ledger.getPublicKeyED25519(bip32Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length %d, expected %d", len(bz), ed25519.PublicKeySize)
+	}
+	pubkey = ed25519.PublicKey(bz)
+
+	ledger.pubkeysMu.Lock()
+	defer ledger.pubkeysMu.Unlock()
+	if ledger.pubkeys == nil {
+		ledger.pubkeys = make(map[string]ed25519.PublicKey)
+	}
+	ledger.pubkeys[key] = pubkey
+	return pubkey, nil
+}
+
+// validatorError classifies the refusal codes of the validator app on top of deviceError
+func validatorError(err error) error {
+	err = deviceError(err)
+
+	var devErr *DeviceError
+	if !errors.As(err, &devErr) || devErr.kind != nil {
+		return err
+	}
+	switch devErr.StatusWord {
+	case swDataInvalid:
+		devErr.kind = ErrSignRefused
+	case swBadKeyHandle, swWrongLength:
+		devErr.kind = ErrInvalidSignBytes
 	}
-	return finalResponse, nil
+	return devErr
 }
@@ -17,10 +17,15 @@
 package ledger_cosmos_go
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	ledger_go "github.com/zondax/ledger-go"
 )
 
 func Test_ValGetVersion(t *testing.T) {
@@ -60,5 +65,183 @@ func Test_ValGetPublicKey(t *testing.T) {
 }
 
 func Test_ValSignED25519(t *testing.T) {
-	t.Skip("Go support is still not available. Please refer to the Rust library")
+	validatorApp, err := FindLedgerTendermintValidatorApp()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	defer validatorApp.Close()
+
+	path := []uint32{44, 118, 0, 0, 0}
+
+	// prevote at height 16, round 1
+	vote := Vote{
+		Type:      PrevoteType,
+		Height:    16,
+		Round:     1,
+		Timestamp: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+	message := vote.SignBytes("test-chain")
+
+	signature, err := validatorApp.SignED25519(path, message)
+	require.Nil(t, err, "Detected error, err: %s\n", err)
+	assert.Len(t, signature, ed25519.SignatureSize)
+}
+
+func testED25519Key() ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte("ledger-cosmos-go validator test key"))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func Test_SignED25519Verified(t *testing.T) {
+	key := testED25519Key()
+	device := fakeSigningValidatorApp(key, nil)
+	validatorApp := &LedgerTendermintValidator{api: device}
+	path := []uint32{44, 118, 0, 0, 0}
+
+	message := bytes.Repeat([]byte{0x5a}, 3*validatorMessageChunkSize+10)
+	signature, err := validatorApp.SignED25519(path, message)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), message, signature))
+
+	// the version selecting the chunking, path packet, four message packets and the public key request
+	sent := device.Sent()
+	require.Len(t, sent, 7)
+	assert.Equal(t, []byte{validatorCLA, validatorINSGetVersion, 0, 0, 0}, sent[0])
+	assert.Equal(t, []byte{validatorCLA, validatorINSSignED25519, 5, 5, 10}, sent[5][:5])
+
+	// the version and public key are only requested once
+	_, err = validatorApp.SignED25519(path, message)
+	require.NoError(t, err)
+	assert.Len(t, device.Sent(), 12)
+}
+
+func Test_SignED25519InvalidResponses(t *testing.T) {
+	key := testED25519Key()
+	path := []uint32{44, 118, 0, 0, 0}
+
+	short := &LedgerTendermintValidator{api: fakeSigningValidatorApp(key, func(signature []byte) []byte {
+		return signature[:63]
+	})}
+	_, err := short.SignED25519(path, []byte{1, 2, 3})
+	assert.ErrorContains(t, err, "invalid signature length 63")
+
+	tampered := &LedgerTendermintValidator{api: fakeSigningValidatorApp(key, func(signature []byte) []byte {
+		signature[0] ^= 0xff
+		return signature
+	})}
+	_, err = tampered.SignED25519(path, []byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+}
+
+func Test_SignED25519MessageTooLarge(t *testing.T) {
+	device := fakeSigningValidatorApp(testED25519Key(), nil)
+	validatorApp := &LedgerTendermintValidator{api: device, version: VersionInfo{Minor: 9}}
+
+	_, err := validatorApp.SignED25519([]uint32{44, 118, 0, 0, 0}, make([]byte, 254*validatorMessageChunkSize+1))
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+	assert.Empty(t, device.Sent())
+
+	_, err = validatorApp.SignED25519([]uint32{44, 118, 0, 0, 0}, make([]byte, 254*validatorMessageChunkSize))
+	assert.NoError(t, err)
+}
+
+func Test_SignED25519RefusalErrors(t *testing.T) {
+	cases := map[uint16]error{
+		swDataInvalid:       ErrSignRefused,
+		swBadKeyHandle:      ErrInvalidSignBytes,
+		swCommandNotAllowed: ErrUserRejected,
+	}
+
+	for sw, expected := range cases {
+		device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+			if apdu[2] == 1 {
+				return nil, nil
+			}
+			return nil, apduError(sw)
+		})
+		validatorApp := &LedgerTendermintValidator{api: device, version: VersionInfo{Minor: 9}}
+
+		_, err := validatorApp.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+		assert.ErrorIs(t, err, expected)
+
+		var devErr *DeviceError
+		require.ErrorAs(t, err, &devErr)
+		assert.Equal(t, sw, devErr.StatusWord)
+	}
+}
+
+func Test_SignED25519Chunked(t *testing.T) {
+	key := testED25519Key()
+	device := fakeChunkedValidatorApp(key, 1)
+	validatorApp := &LedgerTendermintValidator{api: device}
+	path := []uint32{44, 118, 0, 0, 0}
+
+	version, err := validatorApp.GetVersion()
+	require.NoError(t, err)
+	require.NoError(t, validatorApp.CheckVersion(*version))
+
+	message := testVote(PrecommitType, 10, 0).SignBytes("test-chain")
+	signature, err := validatorApp.SignED25519(path, message)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), message, signature))
+
+	var p1 []byte
+	for _, apdu := range device.Sent() {
+		if apdu[1] == validatorINSSignED25519 {
+			p1 = append(p1, apdu[2])
+			assert.Zero(t, apdu[3])
+		}
+	}
+	// the path with init, then the message in add chunks and a last one
+	require.Len(t, p1, 1+(len(message)+ledger_go.DefaultChunkSize-1)/ledger_go.DefaultChunkSize)
+	assert.Equal(t, byte(ledger_go.ChunkInit), p1[0])
+	assert.Equal(t, byte(ledger_go.ChunkLast), p1[len(p1)-1])
+	for _, desc := range p1[1 : len(p1)-1] {
+		assert.Equal(t, byte(ledger_go.ChunkAdd), desc)
+	}
+
+	// the app checks the encoding of the path sent with init
+	_, err = validatorApp.SignED25519([]uint32{44, 118, 0, 0, 1}, message)
+	assert.ErrorIs(t, err, ErrSignRefused)
+
+	// the legacy scheme is kept for apps before 1.0.0
+	legacy := &LedgerTendermintValidator{api: fakeSigningValidatorApp(key, nil)}
+	_, err = legacy.GetVersion()
+	require.NoError(t, err)
+	_, err = legacy.SignED25519(path, message)
+	require.NoError(t, err)
+}
+
+func Test_SignED25519ChunkedErrors(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		switch {
+		case apdu[1] == validatorINSGetVersion:
+			return []byte{0, 2, 0, 0}, nil
+		case apdu[2] == 0:
+			return nil, nil
+		}
+		return nil, apduError(swDataInvalid)
+	})
+	validatorApp := &LedgerTendermintValidator{api: device}
+	_, err := validatorApp.GetVersion()
+	require.NoError(t, err)
+
+	_, err = validatorApp.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrSignRefused)
+}
+
+func Test_ValidatorCheckVersion(t *testing.T) {
+	validatorApp := &LedgerTendermintValidator{}
+
+	assert.NoError(t, validatorApp.CheckVersion(VersionInfo{0, 0, 9, 0}))
+	assert.Error(t, validatorApp.CheckVersion(VersionInfo{0, 0, 4, 0}))
+	assert.NoError(t, validatorApp.CheckVersion(VersionInfo{0, 2, 1, 0}))
+	assert.NoError(t, validatorApp.CheckVersion(VersionInfo{0, 3, 0, 0}))
+
+	// newer apps sign with init/add/last, the version is fetched by the first signature
+	key := testED25519Key()
+	newer := &LedgerTendermintValidator{api: fakeChunkedValidatorApp(key, 3)}
+	signature, err := newer.SignED25519([]uint32{44, 118, 0, 0, 0}, []byte{1, 2, 3})
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), []byte{1, 2, 3}, signature))
 }
@@ -0,0 +1,159 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SignedMsgType is the type of a consensus message signed by a validator
+type SignedMsgType int32
+
+const (
+	PrevoteType   SignedMsgType = 1
+	PrecommitType SignedMsgType = 2
+	ProposalType  SignedMsgType = 32
+)
+
+// PartSetHeader identifies the parts of a block
+type PartSetHeader struct {
+	Total uint32
+	Hash  []byte
+}
+
+// BlockID identifies a block. The zero value is a vote for nil.
+type BlockID struct {
+	Hash          []byte
+	PartSetHeader PartSetHeader
+}
+
+func (id BlockID) isZero() bool {
+	return len(id.Hash) == 0 && id.PartSetHeader.Total == 0 && len(id.PartSetHeader.Hash) == 0
+}
+
+// Vote is a CometBFT prevote or precommit
+type Vote struct {
+	Type      SignedMsgType
+	Height    int64
+	Round     int32
+	BlockID   BlockID
+	Timestamp time.Time
+}
+
+// Proposal is a CometBFT block proposal
+type Proposal struct {
+	Height    int64
+	Round     int32
+	POLRound  int32
+	BlockID   BlockID
+	Timestamp time.Time
+}
+
+// VoteExtension is the application data attached to a precommit
+type VoteExtension struct {
+	Extension []byte
+	Height    int64
+	Round     int32
+}
+
+// SignBytes returns the length prefixed CanonicalVote signed by validators, as in CometBFT VoteSignBytes
+func (v Vote) SignBytes(chainID string) []byte {
+	var b []byte
+	b = appendVarintField(b, 1, uint64(v.Type))
+	b = appendSfixed64Field(b, 2, v.Height)
+	b = appendSfixed64Field(b, 3, int64(v.Round))
+	b = appendCanonicalBlockID(b, 4, v.BlockID)
+	b = appendTimestamp(b, 5, v.Timestamp)
+	b = appendStringField(b, 6, chainID)
+	return protowire.AppendBytes(nil, b)
+}
+
+// SignBytes returns the length prefixed CanonicalProposal signed by validators, as in CometBFT ProposalSignBytes
+func (p Proposal) SignBytes(chainID string) []byte {
+	var b []byte
+	b = appendVarintField(b, 1, uint64(ProposalType))
+	b = appendSfixed64Field(b, 2, p.Height)
+	b = appendSfixed64Field(b, 3, int64(p.Round))
+	b = appendVarintField(b, 4, uint64(int64(p.POLRound)))
+	b = appendCanonicalBlockID(b, 5, p.BlockID)
+	b = appendTimestamp(b, 6, p.Timestamp)
+	b = appendStringField(b, 7, chainID)
+	return protowire.AppendBytes(nil, b)
+}
+
+// SignBytes returns the length prefixed CanonicalVoteExtension, as in CometBFT VoteExtensionSignBytes
+func (e VoteExtension) SignBytes(chainID string) []byte {
+	var b []byte
+	b = appendBytesField(b, 1, e.Extension)
+	b = appendSfixed64Field(b, 2, e.Height)
+	b = appendSfixed64Field(b, 3, int64(e.Round))
+	b = appendStringField(b, 4, chainID)
+	return protowire.AppendBytes(nil, b)
+}
+
+// SignVote signs the canonical sign bytes of vote
+func (ledger *LedgerTendermintValidator) SignVote(bip32Path []uint32, chainID string, vote Vote) ([]byte, error) {
+	return ledger.SignED25519(bip32Path, vote.SignBytes(chainID))
+}
+
+// SignProposal signs the canonical sign bytes of proposal
+func (ledger *LedgerTendermintValidator) SignProposal(bip32Path []uint32, chainID string, proposal Proposal) ([]byte, error) {
+	return ledger.SignED25519(bip32Path, proposal.SignBytes(chainID))
+}
+
+// appendCanonicalBlockID appends a CanonicalBlockID, which is omitted for nil votes
+func appendCanonicalBlockID(b []byte, num protowire.Number, id BlockID) []byte {
+	if id.isZero() {
+		return b
+	}
+
+	var header []byte
+	header = appendVarintField(header, 1, uint64(id.PartSetHeader.Total))
+	header = appendBytesField(header, 2, id.PartSetHeader.Hash)
+
+	var blockID []byte
+	blockID = appendBytesField(blockID, 1, id.Hash)
+	// the part set header is not nullable and is always present
+	blockID = protowire.AppendTag(blockID, 2, protowire.BytesType)
+	blockID = protowire.AppendBytes(blockID, header)
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, blockID)
+}
+
+// appendTimestamp appends a google.protobuf.Timestamp, which is always present even for the zero time
+func appendTimestamp(b []byte, num protowire.Number, t time.Time) []byte {
+	return appendMessageField(b, num, encodeTimestamp(t))
+}
+
+// encodeTimestamp encodes a google.protobuf.Timestamp
+func encodeTimestamp(t time.Time) []byte {
+	var b []byte
+	b = appendVarintField(b, 1, uint64(t.Unix()))
+	return appendVarintField(b, 2, uint64(t.Nanosecond()))
+}
+
+// appendSfixed64Field appends a sfixed64 field, omitted when zero like proto3 does
+func appendSfixed64Field(b []byte, num protowire.Number, value int64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, uint64(value))
+}
@@ -0,0 +1,119 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zeroTimestamp is the Timestamp field of time.Time{}, seconds = -62135596800
+var zeroTimestamp = []byte{0x2a, 0xb, 0x8, 0x80, 0x92, 0xb8, 0xc3, 0x98, 0xfe, 0xff, 0xff, 0xff, 0x1}
+
+func Test_VoteSignBytes(t *testing.T) {
+	// test vectors from CometBFT types/vote_test.go
+	heightRound := []byte{0x11, 0x1, 0, 0, 0, 0, 0, 0, 0, 0x19, 0x1, 0, 0, 0, 0, 0, 0, 0}
+
+	cases := []struct {
+		chainID  string
+		vote     Vote
+		expected []byte
+	}{
+		{"", Vote{}, append([]byte{0xd}, zeroTimestamp...)},
+		{"", Vote{Height: 1, Round: 1, Type: PrecommitType}, concat([]byte{0x21, 0x8, 0x2}, heightRound, zeroTimestamp)},
+		{"", Vote{Height: 1, Round: 1, Type: PrevoteType}, concat([]byte{0x21, 0x8, 0x1}, heightRound, zeroTimestamp)},
+		{"", Vote{Height: 1, Round: 1}, concat([]byte{0x1f}, heightRound, zeroTimestamp)},
+		{"test_chain_id", Vote{Height: 1, Round: 1}, concat([]byte{0x2e}, heightRound, zeroTimestamp, []byte{0x32, 0xd}, []byte("test_chain_id"))},
+	}
+
+	for i, c := range cases {
+		assert.Equal(t, c.expected, c.vote.SignBytes(c.chainID), "case %d", i)
+	}
+}
+
+func Test_VoteSignBytesBlockID(t *testing.T) {
+	hash := bytes.Repeat([]byte{0xaa}, 32)
+	vote := Vote{
+		Type:      PrecommitType,
+		Height:    2,
+		BlockID:   BlockID{Hash: hash, PartSetHeader: PartSetHeader{Total: 1, Hash: hash}},
+		Timestamp: time.Unix(1, 5).UTC(),
+	}
+
+	body := []byte{0x8, 0x2, 0x11, 0x2, 0, 0, 0, 0, 0, 0, 0}
+	body = append(body, 0x22, 72, 0xa, 32)
+	body = append(body, hash...)
+	body = append(body, 0x12, 36, 0x8, 0x1, 0x12, 32)
+	body = append(body, hash...)
+	body = append(body, 0x2a, 0x4, 0x8, 0x1, 0x10, 0x5)
+	body = append(body, 0x32, 0x1, 'c')
+
+	assert.Equal(t, append([]byte{byte(len(body))}, body...), vote.SignBytes("c"))
+
+	// a block id with only a hash still carries an empty part set header
+	vote.BlockID = BlockID{Hash: hash}
+	assert.Contains(t, string(vote.SignBytes("c")), string(append(append([]byte{0x22, 36, 0xa, 32}, hash...), 0x12, 0)))
+}
+
+func Test_ProposalSignBytes(t *testing.T) {
+	proposal := Proposal{Height: 1, Round: 2, POLRound: -1}
+
+	body := []byte{0x8, 0x20, 0x11, 0x1, 0, 0, 0, 0, 0, 0, 0, 0x19, 0x2, 0, 0, 0, 0, 0, 0, 0}
+	body = append(body, 0x20, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1)
+	body = append(body, 0x32)
+	body = append(body, zeroTimestamp[1:]...)
+	body = append(body, 0x3a, 0x2, 'i', 'd')
+
+	assert.Equal(t, append([]byte{byte(len(body))}, body...), proposal.SignBytes("id"))
+}
+
+func Test_VoteExtensionSignBytes(t *testing.T) {
+	extension := VoteExtension{Extension: []byte("ext"), Height: 1, Round: 0}
+
+	body := []byte{0xa, 0x3, 'e', 'x', 't', 0x11, 0x1, 0, 0, 0, 0, 0, 0, 0, 0x22, 0x2, 'i', 'd'}
+	assert.Equal(t, append([]byte{byte(len(body))}, body...), extension.SignBytes("id"))
+}
+
+func Test_SignVoteAndProposal(t *testing.T) {
+	key := testED25519Key()
+	validatorApp := &LedgerTendermintValidator{api: fakeSigningValidatorApp(key, nil)}
+	path := []uint32{44, 118, 0, 0, 0}
+	pubkey := key.Public().(ed25519.PublicKey)
+
+	vote := Vote{Type: PrevoteType, Height: 10, Round: 1, Timestamp: time.Now()}
+	signature, err := validatorApp.SignVote(path, "chain", vote)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(pubkey, vote.SignBytes("chain"), signature))
+
+	proposal := Proposal{Height: 11, POLRound: -1, Timestamp: time.Now()}
+	signature, err = validatorApp.SignProposal(path, "chain", proposal)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(pubkey, proposal.SignBytes("chain"), signature))
+}
+
+func concat(parts ...[]byte) []byte {
+	var result []byte
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result
+}
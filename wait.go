@@ -0,0 +1,238 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+const (
+	dashboardCLA           = 0xB0
+	dashboardINSGetAppName = 0x01
+
+	dashboardAppName = "BOLOS"
+
+	defaultWaitInitialInterval = 250 * time.Millisecond
+	defaultWaitMaxInterval     = 5 * time.Second
+	defaultWaitMultiplier      = 2
+)
+
+// AppState describes what was found on the device while waiting for an app
+type AppState int
+
+const (
+	// AppStateNoDevice means no device could be opened (unplugged, rebooting or in use)
+	AppStateNoDevice AppState = iota
+	// AppStateLocked means the device is connected but the PIN has not been entered
+	AppStateLocked
+	// AppStateDashboard means the device is unlocked but no app is open
+	AppStateDashboard
+	// AppStateWrongApp means a different app is open
+	AppStateWrongApp
+	// AppStateReady means the expected app is open and its version is supported
+	AppStateReady
+	// AppStateUnsupportedVersion means the expected app is open but its version is not supported
+	AppStateUnsupportedVersion
+)
+
+func (s AppState) String() string {
+	switch s {
+	case AppStateNoDevice:
+		return "no device"
+	case AppStateLocked:
+		return "locked"
+	case AppStateDashboard:
+		return "dashboard"
+	case AppStateWrongApp:
+		return "wrong app"
+	case AppStateReady:
+		return "ready"
+	case AppStateUnsupportedVersion:
+		return "unsupported version"
+	default:
+		return fmt.Sprintf("AppState(%d)", int(s))
+	}
+}
+
+// WaitOptions configures how the WaitFor functions poll the device
+type WaitOptions struct {
+	// InitialInterval is the delay after the first unsuccessful attempt. Defaults to 250ms
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between attempts. Defaults to 5s
+	MaxInterval time.Duration
+	// Multiplier is applied to the delay after every unsuccessful attempt. Defaults to 2
+	Multiplier float64
+	// OnStateChange, if set, is called every time the observed state changes
+	OnStateChange func(state AppState)
+}
+
+func (opts WaitOptions) withDefaults() WaitOptions {
+	if opts.InitialInterval <= 0 {
+		opts.InitialInterval = defaultWaitInitialInterval
+	}
+	if opts.MaxInterval <= 0 {
+		opts.MaxInterval = defaultWaitMaxInterval
+	}
+	if opts.MaxInterval < opts.InitialInterval {
+		opts.MaxInterval = opts.InitialInterval
+	}
+	if opts.Multiplier < 1 {
+		opts.Multiplier = defaultWaitMultiplier
+	}
+	return opts
+}
+
+// appConnection is implemented by the app clients that can be waited for
+type appConnection interface {
+	GetVersion() (*VersionInfo, error)
+	Close() error
+}
+
+// WaitForCosmosUserApp polls until the Cosmos user app is open on a device and returns a connected client.
+// It gives up when ctx is done or the app version is not supported.
+func WaitForCosmosUserApp(ctx context.Context, opts WaitOptions) (*LedgerCosmos, error) {
+	return waitForApp(ctx, opts,
+		func(device ledger_go.LedgerDevice) *LedgerCosmos {
+			return &LedgerCosmos{api: device}
+		},
+		func(app *LedgerCosmos, version VersionInfo) error {
+			return app.CheckVersion(version)
+		})
+}
+
+// WaitForTendermintValidatorApp polls until the Tendermint validator app is open on a device and returns a connected client.
+// It gives up when ctx is done or the app version is not supported.
+func WaitForTendermintValidatorApp(ctx context.Context, opts WaitOptions) (*LedgerTendermintValidator, error) {
+	return waitForApp(ctx, opts,
+		func(device ledger_go.LedgerDevice) *LedgerTendermintValidator {
+			return &LedgerTendermintValidator{api: device}
+		},
+		func(app *LedgerTendermintValidator, version VersionInfo) error {
+			return app.CheckVersion(version)
+		})
+}
+
+func waitForApp[T appConnection](
+	ctx context.Context,
+	opts WaitOptions,
+	newApp func(ledger_go.LedgerDevice) T,
+	checkApp func(T, VersionInfo) error,
+) (T, error) {
+	var zero T
+	opts = opts.withDefaults()
+
+	interval := opts.InitialInterval
+	lastState := AppState(-1)
+	for {
+		app, state, err := probeApp(newApp, checkApp)
+		if state != lastState {
+			lastState = state
+			if opts.OnStateChange != nil {
+				opts.OnStateChange(state)
+			}
+		}
+		switch state {
+		case AppStateReady:
+			return app, nil
+		case AppStateUnsupportedVersion:
+			// the app is open but cannot be used, retrying will not help
+			return zero, err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, fmt.Errorf("%w: device state is %s: %v", ctx.Err(), state, err)
+		case <-timer.C:
+		}
+
+		interval = time.Duration(float64(interval) * opts.Multiplier)
+		if interval > opts.MaxInterval {
+			interval = opts.MaxInterval
+		}
+	}
+}
+
+// probeApp makes a single connection attempt and classifies the result.
+// A nil error is only returned together with AppStateReady.
+func probeApp[T appConnection](
+	newApp func(ledger_go.LedgerDevice) T,
+	checkApp func(T, VersionInfo) error,
+) (_ T, _ AppState, rerr error) {
+	var zero T
+
+	device, err := newLedgerAdmin().Connect(0)
+	if err != nil {
+		return zero, AppStateNoDevice, err
+	}
+
+	defer func() {
+		if rerr != nil {
+			device.Close()
+		}
+	}()
+
+	name, err := getAppName(device)
+	switch {
+	case err == nil && name == dashboardAppName:
+		return zero, AppStateDashboard, errors.New("no app is open on the device")
+	case isDeviceLocked(err):
+		return zero, AppStateLocked, err
+	}
+
+	app := newApp(device)
+	version, err := app.GetVersion()
+	if err != nil {
+		switch _, isStatus := statusWordFromError(err); {
+		case isDeviceLocked(err):
+			return zero, AppStateLocked, err
+		case isStatus:
+			return zero, AppStateWrongApp, err
+		default:
+			// transport errors are expected while the device reboots or switches apps
+			return zero, AppStateNoDevice, err
+		}
+	}
+
+	if err := checkApp(app, *version); err != nil {
+		return zero, AppStateUnsupportedVersion, err
+	}
+
+	return app, AppStateReady, nil
+}
+
+// getAppName asks the device OS for the name of the running app. It returns "BOLOS" on the dashboard.
+func getAppName(device ledger_go.LedgerDevice) (string, error) {
+	message := []byte{dashboardCLA, dashboardINSGetAppName, 0, 0, 0}
+	response, err := device.Exchange(message)
+	if err != nil {
+		return "", err
+	}
+
+	// format | name len | name | version len | version | ...
+	if len(response) < 2 || response[0] != 1 || len(response) < 2+int(response[1]) {
+		return "", errors.New("invalid response")
+	}
+
+	return string(response[2 : 2+int(response[1])]), nil
+}
@@ -0,0 +1,130 @@
+/*******************************************************************************
+*   (c) Zondax AG
+*
+*  Licensed under the Apache License, Version 2.0 (the "License");
+*  you may not use this file except in compliance with the License.
+*  You may obtain a copy of the License at
+*
+*      http://www.apache.org/licenses/LICENSE-2.0
+*
+*  Unless required by applicable law or agreed to in writing, software
+*  distributed under the License is distributed on an "AS IS" BASIS,
+*  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*  See the License for the specific language governing permissions and
+*  limitations under the License.
+********************************************************************************/
+
+package ledger_cosmos_go
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ledger_go "github.com/zondax/ledger-go"
+)
+
+var fastWait = WaitOptions{
+	InitialInterval: time.Millisecond,
+	MaxInterval:     2 * time.Millisecond,
+}
+
+func Test_WaitForCosmosUserApp_Transitions(t *testing.T) {
+	attempt := 0
+	useFakeAdmin(t, func() (ledger_go.LedgerDevice, error) {
+		attempt++
+		switch attempt {
+		case 1:
+			return nil, errors.New("LedgerHID device (idx 0) not found")
+		case 2:
+			return newFakeDevice(func([]byte) ([]byte, error) {
+				return nil, apduError(swDeviceLocked)
+			}), nil
+		case 3:
+			return newFakeDevice(func(apdu []byte) ([]byte, error) {
+				return appNameResponse(dashboardAppName), nil
+			}), nil
+		case 4:
+			return newFakeDevice(func(apdu []byte) ([]byte, error) {
+				if apdu[0] == dashboardCLA {
+					return appNameResponse("Bitcoin"), nil
+				}
+				return nil, apduError(swCLANotSupported)
+			}), nil
+		default:
+			return newFakeDevice(func(apdu []byte) ([]byte, error) {
+				if apdu[0] == dashboardCLA {
+					return appNameResponse("Cosmos"), nil
+				}
+				return []byte{0, 2, 30, 0}, nil
+			}), nil
+		}
+	})
+
+	var states []AppState
+	opts := fastWait
+	opts.OnStateChange = func(state AppState) {
+		states = append(states, state)
+	}
+
+	app, err := WaitForCosmosUserApp(context.Background(), opts)
+	require.NoError(t, err)
+	require.NotNil(t, app)
+
+	assert.Equal(t, []AppState{
+		AppStateNoDevice,
+		AppStateLocked,
+		AppStateDashboard,
+		AppStateWrongApp,
+		AppStateReady,
+	}, states)
+	assert.Equal(t, uint8(2), app.version.Major)
+}
+
+func Test_WaitForCosmosUserApp_UnsupportedVersion(t *testing.T) {
+	device := newFakeDevice(func(apdu []byte) ([]byte, error) {
+		if apdu[0] == dashboardCLA {
+			return appNameResponse("Cosmos"), nil
+		}
+		return []byte{0, 2, 0, 0}, nil
+	})
+	useFakeAdmin(t, func() (ledger_go.LedgerDevice, error) {
+		return device, nil
+	})
+
+	var states []AppState
+	opts := fastWait
+	opts.OnStateChange = func(state AppState) {
+		states = append(states, state)
+	}
+
+	_, err := WaitForCosmosUserApp(context.Background(), opts)
+	var versionErr *VersionRequiredError
+	require.ErrorAs(t, err, &versionErr)
+	assert.Equal(t, []AppState{AppStateUnsupportedVersion}, states)
+	assert.True(t, device.IsClosed())
+}
+
+func Test_WaitForTendermintValidatorApp_ContextDone(t *testing.T) {
+	useFakeAdmin(t, func() (ledger_go.LedgerDevice, error) {
+		return newFakeDevice(func(apdu []byte) ([]byte, error) {
+			return appNameResponse(dashboardAppName), nil
+		}), nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var states []AppState
+	opts := fastWait
+	opts.OnStateChange = func(state AppState) {
+		states = append(states, state)
+	}
+
+	_, err := WaitForTendermintValidatorApp(ctx, opts)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []AppState{AppStateDashboard}, states)
+}